@@ -0,0 +1,374 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/purple-mountain/snippetbox/internal/highlight"
+	"github.com/purple-mountain/snippetbox/internal/models"
+	"github.com/purple-mountain/snippetbox/internal/validator"
+)
+
+const (
+	maxJSONBytes    = 1 << 20
+	maxAPIPageLimit = 100
+)
+
+// problem is an RFC 9457 problem details object. Errors holds the field
+// errors of a failed validation.
+type problem struct {
+	Type   string            `json:"type"`
+	Title  string            `json:"title"`
+	Status int               `json:"status"`
+	Detail string            `json:"detail,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+type apiSnippet struct {
+	ID       int       `json:"id"`
+	Author   string    `json:"author"`
+	Title    string    `json:"title"`
+	Content  string    `json:"content"`
+	Language string    `json:"language"`
+	Version  int       `json:"version"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+	Expires  time.Time `json:"expires"`
+}
+
+type apiSnippetList struct {
+	Snippets []apiSnippet `json:"snippets"`
+	Older    string       `json:"older,omitempty"`
+	Newer    string       `json:"newer,omitempty"`
+}
+
+type apiSnippetCreateInput struct {
+	Title               string `json:"title"`
+	Content             string `json:"content"`
+	Language            string `json:"language"`
+	Expires             int    `json:"expires"`
+	validator.Validator `json:"-"`
+}
+
+// apiSnippetUpdateInput holds a partial update; fields left out of the
+// request body are nil and keep their current value.
+type apiSnippetUpdateInput struct {
+	Title               *string `json:"title"`
+	Content             *string `json:"content"`
+	Language            *string `json:"language"`
+	validator.Validator `json:"-"`
+}
+
+func newAPISnippet(s *models.Snippet) apiSnippet {
+	return apiSnippet{
+		ID:       s.ID,
+		Author:   s.Author,
+		Title:    s.Title,
+		Content:  s.Content,
+		Language: s.Language,
+		Version:  s.Version,
+		Created:  s.Created,
+		Updated:  s.Updated,
+		Expires:  s.Expires,
+	}
+}
+
+func (app *application) apiProblem(w http.ResponseWriter, r *http.Request, p problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	app.writeJSON(w, r, p.Status, p)
+}
+
+func (app *application) apiError(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	app.apiProblem(w, r, problem{Status: status, Detail: detail})
+}
+
+func (app *application) apiNotFound(w http.ResponseWriter, r *http.Request) {
+	app.apiError(w, r, http.StatusNotFound, "The requested resource could not be found.")
+}
+
+func (app *application) apiFailedValidation(w http.ResponseWriter, r *http.Request, v validator.Validator) {
+	app.apiProblem(w, r, problem{
+		Status: http.StatusUnprocessableEntity,
+		Detail: strings.Join(v.NonFieldErrors, " "),
+		Errors: v.FieldErrors,
+	})
+}
+
+func (app *application) apiBadRequest(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		app.apiError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("The body must not be larger than %d bytes.", maxBytesError.Limit))
+		return
+	}
+	app.apiError(w, r, http.StatusBadRequest, err.Error())
+}
+
+// apiServerError is serverError for API clients: the error is logged the same
+// way, but the response is a problem.
+func (app *application) apiServerError(w http.ResponseWriter, r *http.Request, err error) {
+	status, detail := app.logServerError(r, err)
+	switch status {
+	case 0:
+		return
+	case http.StatusServiceUnavailable:
+		w.Header().Set("Retry-After", "5")
+	}
+	app.apiError(w, r, status, detail)
+}
+
+// apiAuthenticate signs in API clients by their bearer token. There is no
+// session, so requests without a token are anonymous.
+func (app *application) apiAuthenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+		apiToken, err := app.bearerToken(r)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidToken) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				app.apiError(w, r, http.StatusUnauthorized, "The API token is invalid or has been revoked.")
+			} else {
+				app.apiServerError(w, r, err)
+			}
+			return
+		}
+		if apiToken == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !tokenAllows(apiToken, r) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+			app.apiError(w, r, http.StatusForbidden, "The API token does not have the write scope.")
+			return
+		}
+		next.ServeHTTP(w, withAPIToken(r, apiToken))
+	})
+}
+
+func (app *application) apiRequireAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			app.apiError(w, r, http.StatusUnauthorized, "You must send an API token to access this resource.")
+			return
+		}
+		w.Header().Add("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) apiRequireVerified(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := app.users.GetUser(r.Context(), app.authenticatedUserID(r))
+		if err != nil {
+			app.apiServerError(w, r, err)
+			return
+		}
+		if !user.Verified {
+			app.apiError(w, r, http.StatusForbidden, "Please verify your email address before creating snippets.")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) apiOwnedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.apiVisibleSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+	if snippet.UserID != app.authenticatedUserID(r) {
+		app.apiError(w, r, http.StatusForbidden, "You can only change your own snippets.")
+		return nil, false
+	}
+	return snippet, true
+}
+
+func (app *application) apiVisibleSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.apiNotFound(w, r)
+		return nil, false
+	}
+	snippet, err := app.snippets.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return nil, false
+	}
+	return snippet, true
+}
+
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	q := models.PageQuery{Limit: snippetsPerPage}
+	query := r.URL.Query()
+	var v validator.Validator
+	if query.Has("limit") {
+		limit, err := strconv.Atoi(query.Get("limit"))
+		v.AddFieldError(err == nil && limit >= 1 && limit <= maxAPIPageLimit, "limit", fmt.Sprintf("This field must be between 1 and %d", maxAPIPageLimit))
+		q.Limit = limit
+	}
+	if query.Has("before") {
+		cursor, err := models.ParseCursor(query.Get("before"))
+		v.AddFieldError(err == nil, "before", "This field must be a cursor from an earlier response")
+		q.Before = &cursor
+	} else if query.Has("after") {
+		cursor, err := models.ParseCursor(query.Get("after"))
+		v.AddFieldError(err == nil, "after", "This field must be a cursor from an earlier response")
+		q.After = &cursor
+	}
+	if !v.IsValid() {
+		app.apiFailedValidation(w, r, v)
+		return
+	}
+
+	page, err := app.snippets.Latest(r.Context(), q)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	list := apiSnippetList{Snippets: make([]apiSnippet, 0, len(page.Snippets))}
+	for _, s := range page.Snippets {
+		list.Snippets = append(list.Snippets, newAPISnippet(s))
+	}
+	if page.Older != nil {
+		list.Older = page.Older.String()
+	}
+	if page.Newer != nil {
+		list.Newer = page.Newer.String()
+	}
+	app.writeJSON(w, r, http.StatusOK, list)
+}
+
+func (app *application) apiSnippetView(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiVisibleSnippet(w, r)
+	if !ok {
+		return
+	}
+	app.writeJSON(w, r, http.StatusOK, newAPISnippet(snippet))
+}
+
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	input := apiSnippetCreateInput{Expires: 365}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiBadRequest(w, r, err)
+		return
+	}
+
+	input.AddFieldError(validator.NotBlank(input.Title), "title", "This field cannot be blank")
+	input.AddFieldError(validator.LowerThanMaxChars(input.Title, 100), "title", "This field cannot be more than 100 characters long")
+	input.AddFieldError(validator.NotBlank(input.Content), "content", "This field cannot be blank")
+	input.AddFieldError(input.Language == "" || validator.PermittedValue(input.Language, highlight.Names()...), "language", "This field must be a supported language")
+	input.AddFieldError(validator.PermittedValue(input.Expires, 365, 7, 1), "expires", "This field must be equal 1, 7 or 365")
+
+	if !input.IsValid() {
+		app.apiFailedValidation(w, r, input.Validator)
+		return
+	}
+
+	language, confidence := detectLanguage(input.Title, input.Content, input.Language)
+	id, err := app.snippets.Insert(r.Context(), app.authenticatedUserID(r), input.Title, input.Content, language, confidence, input.Expires)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	snippet, err := app.snippets.Get(r.Context(), id)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/snippets/%d", id))
+	app.writeJSON(w, r, http.StatusCreated, newAPISnippet(snippet))
+}
+
+func (app *application) apiSnippetUpdate(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var input apiSnippetUpdateInput
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiBadRequest(w, r, err)
+		return
+	}
+
+	title, content, language := snippet.Title, snippet.Content, snippet.Language
+	if snippet.LanguageConfidence > 0 {
+		language = ""
+	}
+	if input.Title != nil {
+		title = *input.Title
+	}
+	if input.Content != nil {
+		content = *input.Content
+	}
+	if input.Language != nil {
+		language = *input.Language
+	}
+
+	input.AddFieldError(validator.NotBlank(title), "title", "This field cannot be blank")
+	input.AddFieldError(validator.LowerThanMaxChars(title, 100), "title", "This field cannot be more than 100 characters long")
+	input.AddFieldError(validator.NotBlank(content), "content", "This field cannot be blank")
+	input.AddFieldError(language == "" || validator.PermittedValue(language, highlight.Names()...), "language", "This field must be a supported language")
+
+	if !input.IsValid() {
+		app.apiFailedValidation(w, r, input.Validator)
+		return
+	}
+
+	unchangedLanguage := language == snippet.Language || (language == "" && snippet.LanguageConfidence > 0)
+	if title == snippet.Title && content == snippet.Content && unchangedLanguage {
+		app.writeJSON(w, r, http.StatusOK, newAPISnippet(snippet))
+		return
+	}
+
+	language, confidence := detectLanguage(title, content, language)
+	err = app.snippets.Update(r.Context(), snippet.ID, title, content, language, confidence)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+	snippet, err = app.snippets.Get(r.Context(), snippet.ID)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	app.writeJSON(w, r, http.StatusOK, newAPISnippet(snippet))
+}
+
+func (app *application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+	err := app.snippets.Delete(r.Context(), snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
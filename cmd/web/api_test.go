@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/purple-mountain/snippetbox/internal/assert"
+	"github.com/purple-mountain/snippetbox/internal/mocks"
+	"github.com/purple-mountain/snippetbox/internal/models"
+)
+
+// createdSnippetModel returns the snippet the mock inserts when it is read
+// back, which the plain mock treats as missing.
+type createdSnippetModel struct {
+	mocks.SnippetModel
+}
+
+func (m *createdSnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
+	if id == 2 {
+		return &models.Snippet{ID: 2, UserID: 1, Author: "Alice", Title: "From CI", Content: "echo hello", Language: "bash", Version: 1, Created: time.Now()}, nil
+	}
+	return m.SnippetModel.Get(ctx, id)
+}
+
+func TestAPISnippetList(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, body := ts.do(t, http.MethodGet, "/api/v1/snippets", "", "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "application/json")
+	var list apiSnippetList
+	err := json.Unmarshal([]byte(body), &list)
+	assert.NilError(t, err)
+	assert.Equal(t, len(list.Snippets), 1)
+	assert.Equal(t, list.Snippets[0].Title, "An old silent pond")
+
+	code, header, body = ts.do(t, http.MethodGet, "/api/v1/snippets?limit=0&before=nope", "", "")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.Equal(t, header.Get("Content-Type"), "application/problem+json")
+	var p problem
+	err = json.Unmarshal([]byte(body), &p)
+	assert.NilError(t, err)
+	assert.Equal(t, p.Status, http.StatusUnprocessableEntity)
+	assert.Equal(t, p.Errors["limit"], "This field must be between 1 and 100")
+	assert.Equal(t, p.Errors["before"], "This field must be a cursor from an earlier response")
+}
+
+func TestAPISnippetView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		token       string
+		wantCode    int
+		wantType    string
+		wantContent string
+	}{
+		{
+			name:        "Valid ID",
+			urlPath:     "/api/v1/snippets/1",
+			wantCode:    http.StatusOK,
+			wantType:    "application/json",
+			wantContent: `"title":"An old silent pond"`,
+		},
+		{
+			name:        "Non-existent ID",
+			urlPath:     "/api/v1/snippets/2",
+			wantCode:    http.StatusNotFound,
+			wantType:    "application/problem+json",
+			wantContent: `"status":404`,
+		},
+		{
+			name:        "Unknown Route",
+			urlPath:     "/api/v1/users",
+			wantCode:    http.StatusNotFound,
+			wantType:    "application/problem+json",
+			wantContent: `"title":"Not Found"`,
+		},
+		{
+			name:        "Revoked Token",
+			urlPath:     "/api/v1/snippets/1",
+			token:       "sbx_revoked",
+			wantCode:    http.StatusUnauthorized,
+			wantType:    "application/problem+json",
+			wantContent: "The API token is invalid or has been revoked.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.do(t, http.MethodGet, tt.urlPath, tt.token, "")
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Content-Type"), tt.wantType)
+			assert.StringContains(t, body, tt.wantContent)
+		})
+	}
+}
+
+func TestAPISnippetCreate(t *testing.T) {
+	app := newTestApplication(t)
+	app.snippets = &createdSnippetModel{}
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	const validBody = `{"title": "From CI", "content": "echo hello", "language": "bash"}`
+
+	tests := []struct {
+		name         string
+		token        string
+		body         string
+		wantCode     int
+		wantLocation string
+		wantContent  string
+	}{
+		{
+			name:         "Valid Submission",
+			token:        "sbx_write",
+			body:         validBody,
+			wantCode:     http.StatusCreated,
+			wantLocation: "/api/v1/snippets/2",
+			wantContent:  `"title":"From CI"`,
+		},
+		{
+			name:        "No Token",
+			body:        validBody,
+			wantCode:    http.StatusUnauthorized,
+			wantContent: "You must send an API token",
+		},
+		{
+			name:        "Read Token",
+			token:       "sbx_read",
+			body:        validBody,
+			wantCode:    http.StatusForbidden,
+			wantContent: "The API token does not have the write scope.",
+		},
+		{
+			name:        "Unverified User",
+			token:       "sbx_unverified",
+			body:        validBody,
+			wantCode:    http.StatusForbidden,
+			wantContent: "Please verify your email address",
+		},
+		{
+			name:        "Badly-formed JSON",
+			token:       "sbx_write",
+			body:        `{"title": "From CI",}`,
+			wantCode:    http.StatusBadRequest,
+			wantContent: "body contains badly-formed JSON (at character 21)",
+		},
+		{
+			name:        "Unknown Field",
+			token:       "sbx_write",
+			body:        `{"title": "From CI", "public": true}`,
+			wantCode:    http.StatusBadRequest,
+			wantContent: `body contains unknown field \"public\"`,
+		},
+		{
+			name:        "Wrong Type",
+			token:       "sbx_write",
+			body:        `{"title": "From CI", "expires": "7"}`,
+			wantCode:    http.StatusBadRequest,
+			wantContent: `body contains incorrect JSON type for field \"expires\"`,
+		},
+		{
+			name:        "Multiple Values",
+			token:       "sbx_write",
+			body:        validBody + validBody,
+			wantCode:    http.StatusBadRequest,
+			wantContent: "body must only contain a single JSON value",
+		},
+		{
+			name:        "Too Large",
+			token:       "sbx_write",
+			body:        `{"title": "From CI", "content": "` + strings.Repeat("a", maxJSONBytes) + `"}`,
+			wantCode:    http.StatusRequestEntityTooLarge,
+			wantContent: "The body must not be larger than 1048576 bytes.",
+		},
+		{
+			name:        "Invalid Fields",
+			token:       "sbx_write",
+			body:        `{"title": "", "content": "echo hello", "expires": 30}`,
+			wantCode:    http.StatusUnprocessableEntity,
+			wantContent: `"errors":{"expires":"This field must be equal 1, 7 or 365","title":"This field cannot be blank"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.do(t, http.MethodPost, "/api/v1/snippets", tt.token, tt.body)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+			assert.StringContains(t, body, tt.wantContent)
+		})
+	}
+}
+
+func TestAPISnippetUpdate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		body        string
+		wantCode    int
+		wantContent string
+	}{
+		{
+			name:        "Valid Update",
+			urlPath:     "/api/v1/snippets/1",
+			body:        `{"content": "An old silent pond, a frog jumps in"}`,
+			wantCode:    http.StatusOK,
+			wantContent: `"id":1`,
+		},
+		{
+			name:        "Blank Title",
+			urlPath:     "/api/v1/snippets/1",
+			body:        `{"title": " "}`,
+			wantCode:    http.StatusUnprocessableEntity,
+			wantContent: `"errors":{"title":"This field cannot be blank"}`,
+		},
+		{
+			name:        "Foreign Snippet",
+			urlPath:     "/api/v1/snippets/3",
+			body:        `{"title": "Mine now"}`,
+			wantCode:    http.StatusForbidden,
+			wantContent: "You can only change your own snippets.",
+		},
+		{
+			name:        "Non-existent ID",
+			urlPath:     "/api/v1/snippets/2",
+			body:        `{"title": "Anything"}`,
+			wantCode:    http.StatusNotFound,
+			wantContent: `"status":404`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.do(t, http.MethodPatch, tt.urlPath, "sbx_write", tt.body)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantContent)
+		})
+	}
+}
+
+func TestAPISnippetDelete(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		token    string
+		wantCode int
+	}{
+		{
+			name:     "Own Snippet",
+			urlPath:  "/api/v1/snippets/1",
+			token:    "sbx_write",
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "Foreign Snippet",
+			urlPath:  "/api/v1/snippets/3",
+			token:    "sbx_write",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Read Token",
+			urlPath:  "/api/v1/snippets/1",
+			token:    "sbx_read",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "No Token",
+			urlPath:  "/api/v1/snippets/1",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.do(t, http.MethodDelete, tt.urlPath, tt.token, "")
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const envPrefix = "SNIPPETBOX_"
+
+// envAliases maps environment variables that predate the SNIPPETBOX_ prefix
+// to the setting they configure.
+var envAliases = map[string]string{
+	"DATABASE_URL": "dsn",
+}
+
+// secretSettings are redacted by -print-config.
+var secretSettings = map[string]bool{
+	"dsn":           true,
+	"smtp-password": true,
+}
+
+// loaderSettings control where the configuration is read from and are not part
+// of the configuration themselves.
+var loaderSettings = map[string]bool{
+	"config":       true,
+	"env-file":     true,
+	"print-config": true,
+}
+
+type mailConfig struct {
+	mailer   string
+	sender   string
+	addr     string
+	username string
+	password string
+}
+
+type config struct {
+	addr            string
+	adminAddr       string
+	dsn             string
+	debug           bool
+	logFormat       string
+	migrate         bool
+	db              poolConfig
+	queryTimeout    time.Duration
+	trace           traceConfig
+	idleTimeout     time.Duration
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+	sessionLifetime time.Duration
+	sessionStore    string
+	sessionCleanup  time.Duration
+	bcryptCost      int
+	baseURL         string
+	clientIPHeader  string
+	mail            mailConfig
+	shutdownDrain   time.Duration
+	shutdownGrace   time.Duration
+
+	configFile  string
+	envFile     string
+	printConfig bool
+	flags       *flag.FlagSet
+}
+
+func newFlagSet(cfg *config) *flag.FlagSet {
+	fs := flag.NewFlagSet("web", flag.ContinueOnError)
+	cfg.flags = fs
+
+	fs.StringVar(&cfg.configFile, "config", "", "JSON file to read settings from")
+	fs.StringVar(&cfg.envFile, "env-file", ".env", "dotenv file to read settings from, if it exists")
+	fs.BoolVar(&cfg.printConfig, "print-config", false, "Print the resolved configuration and exit")
+
+	fs.StringVar(&cfg.addr, "addr", ":8080", "HTTP network address")
+	fs.StringVar(&cfg.adminAddr, "admin-addr", "localhost:9091", "Admin HTTP network address serving /metrics (empty to disable)")
+	fs.StringVar(&cfg.dsn, "dsn", "", "PostgreSQL connection string (also read from DATABASE_URL)")
+	fs.BoolVar(&cfg.debug, "debug", false, "Enable debug mode")
+	fs.StringVar(&cfg.logFormat, "log-format", "text", "Log format (text|json)")
+	fs.BoolVar(&cfg.migrate, "migrate", false, "Apply pending database migrations at startup")
+
+	fs.IntVar(&cfg.db.maxConns, "db-max-conns", 10, "Maximum number of open database connections")
+	fs.IntVar(&cfg.db.minConns, "db-min-conns", 0, "Minimum number of idle database connections to keep open")
+	fs.DurationVar(&cfg.db.maxConnLifetime, "db-max-conn-lifetime", time.Hour, "Maximum lifetime of a database connection")
+	fs.DurationVar(&cfg.db.maxConnIdleTime, "db-max-conn-idle-time", 30*time.Minute, "Maximum time a database connection may sit idle")
+	fs.DurationVar(&cfg.db.healthCheckPeriod, "db-health-check-period", time.Minute, "Interval between health checks of idle database connections")
+	fs.DurationVar(&cfg.queryTimeout, "db-query-timeout", 5*time.Second, "Maximum duration of a single database query")
+
+	fs.StringVar(&cfg.trace.exporter, "trace-exporter", "none", "Trace exporter (none|stdout|file|otlp)")
+	fs.StringVar(&cfg.trace.file, "trace-file", "", "File the file trace exporter appends to")
+	fs.StringVar(&cfg.trace.endpoint, "trace-endpoint", "", "OTLP/HTTP endpoint URL (defaults to OTEL_EXPORTER_OTLP_ENDPOINT)")
+	fs.Float64Var(&cfg.trace.sampleRatio, "trace-sample-ratio", 1, "Fraction of new traces to sample")
+
+	fs.DurationVar(&cfg.idleTimeout, "http-idle-timeout", time.Minute, "Maximum time to keep an idle keep-alive connection open")
+	fs.DurationVar(&cfg.readTimeout, "http-read-timeout", 5*time.Second, "Maximum duration for reading a request")
+	fs.DurationVar(&cfg.writeTimeout, "http-write-timeout", 10*time.Second, "Maximum duration for writing a response")
+	fs.DurationVar(&cfg.sessionLifetime, "session-lifetime", 12*time.Hour, "Lifetime of a login session")
+	fs.StringVar(&cfg.sessionStore, "session-store", "postgres", "Session store (memory|postgres)")
+	fs.DurationVar(&cfg.sessionCleanup, "session-cleanup-interval", 5*time.Minute, "Interval between sweeps of expired sessions")
+	fs.IntVar(&cfg.bcryptCost, "bcrypt-cost", 12, "bcrypt cost used to hash passwords")
+	fs.StringVar(&cfg.clientIPHeader, "client-ip-header", "", "Header the proxy in front of the server sets to the client IP (such as Fly-Client-IP)")
+	fs.StringVar(&cfg.baseURL, "base-url", "http://localhost:8080", "Public URL of the site, used for links in emails")
+
+	fs.StringVar(&cfg.mail.mailer, "mailer", "log", "Mailer (log|smtp)")
+	fs.StringVar(&cfg.mail.sender, "mail-sender", "Snippetbox <no-reply@snippetbox.local>", "From address of outgoing email")
+	fs.StringVar(&cfg.mail.addr, "smtp-addr", "", "SMTP server address as host:port")
+	fs.StringVar(&cfg.mail.username, "smtp-username", "", "SMTP username")
+	fs.StringVar(&cfg.mail.password, "smtp-password", "", "SMTP password")
+
+	fs.DurationVar(&cfg.shutdownDrain, "shutdown-drain", 5*time.Second, "Time to keep serving while /readyz reports not ready before shutting down")
+	fs.DurationVar(&cfg.shutdownGrace, "shutdown-grace", 20*time.Second, "Time allowed for in-flight requests and background tasks to finish on shutdown")
+
+	return fs
+}
+
+// loadConfig resolves the configuration from, in increasing order of
+// precedence, the JSON config file, the dotenv file, the environment and the
+// command line flags. It returns the arguments left after the flags.
+func loadConfig(args []string, environ []string) (*config, []string, error) {
+	cfg := &config{}
+	fs := newFlagSet(cfg)
+	fs.SetOutput(io.Discard)
+	err := fs.Parse(args)
+	if err != nil {
+		fs.SetOutput(os.Stderr)
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		fs.PrintDefaults()
+		return nil, nil, err
+	}
+
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	env := map[string]string{}
+	for _, kv := range environ {
+		k, v, _ := strings.Cut(kv, "=")
+		env[k] = v
+	}
+	if !explicit["config"] && env[envPrefix+"CONFIG"] != "" {
+		cfg.configFile = env[envPrefix+"CONFIG"]
+	}
+
+	layers := []map[string]string{}
+	if cfg.configFile != "" {
+		settings, err := readConfigFile(cfg.configFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		layers = append(layers, settings)
+	}
+	dotenv, err := godotenv.Read(cfg.envFile)
+	if err != nil && !(errors.Is(err, os.ErrNotExist) && !explicit["env-file"]) {
+		return nil, nil, err
+	}
+	layers = append(layers, envSettings(fs, dotenv), envSettings(fs, env))
+
+	for _, layer := range layers {
+		for name, value := range layer {
+			if explicit[name] || loaderSettings[name] {
+				continue
+			}
+			if fs.Lookup(name) == nil {
+				return nil, nil, fmt.Errorf("config: unknown setting %q", name)
+			}
+			err := fs.Set(name, value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("config: invalid value %q for %s: %w", value, name, err)
+			}
+		}
+	}
+
+	if cfg.printConfig {
+		return cfg, fs.Args(), nil
+	}
+	err = cfg.validate()
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err = dec.Decode(&raw)
+	if err != nil {
+		return nil, fmt.Errorf("config: %s: %w", path, err)
+	}
+
+	settings := map[string]string{}
+	for name, v := range raw {
+		switch v := v.(type) {
+		case string:
+			settings[name] = v
+		case json.Number:
+			settings[name] = v.String()
+		case bool:
+			settings[name] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("config: %s: %s must be a string, number or boolean", path, name)
+		}
+	}
+	return settings, nil
+}
+
+// envSettings picks the variables that configure a flag, such as
+// SNIPPETBOX_DB_MAX_CONNS for -db-max-conns, out of env.
+func envSettings(fs *flag.FlagSet, env map[string]string) map[string]string {
+	settings := map[string]string{}
+	for k, name := range envAliases {
+		if v, ok := env[k]; ok {
+			settings[name] = v
+		}
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		k := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if v, ok := env[k]; ok {
+			settings[f.Name] = v
+		}
+	})
+	return settings
+}
+
+func (cfg *config) validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(cfg.addr != "", "addr must not be empty")
+	check(cfg.dsn != "", "dsn must be set (or DATABASE_URL)")
+	check(cfg.logFormat == "text" || cfg.logFormat == "json", "log-format must be text or json")
+	check(cfg.db.maxConns >= 1, "db-max-conns must be at least 1")
+	check(cfg.db.minConns >= 0 && cfg.db.minConns <= cfg.db.maxConns, "db-min-conns must be between 0 and db-max-conns")
+	check(cfg.db.maxConnLifetime > 0, "db-max-conn-lifetime must be positive")
+	check(cfg.db.maxConnIdleTime > 0, "db-max-conn-idle-time must be positive")
+	check(cfg.db.healthCheckPeriod > 0, "db-health-check-period must be positive")
+	check(cfg.queryTimeout > 0, "db-query-timeout must be positive")
+	switch cfg.trace.exporter {
+	case "none", "stdout", "otlp":
+	case "file":
+		check(cfg.trace.file != "", "trace-file must be set when trace-exporter is file")
+	default:
+		check(false, "trace-exporter must be none, stdout, file or otlp")
+	}
+	check(cfg.trace.sampleRatio >= 0 && cfg.trace.sampleRatio <= 1, "trace-sample-ratio must be between 0 and 1")
+	check(cfg.idleTimeout > 0, "http-idle-timeout must be positive")
+	check(cfg.readTimeout > 0, "http-read-timeout must be positive")
+	check(cfg.writeTimeout > 0, "http-write-timeout must be positive")
+	check(cfg.sessionLifetime > 0, "session-lifetime must be positive")
+	check(cfg.sessionStore == "memory" || cfg.sessionStore == "postgres", "session-store must be memory or postgres")
+	check(cfg.sessionCleanup > 0, "session-cleanup-interval must be positive")
+	check(cfg.bcryptCost >= bcrypt.MinCost && cfg.bcryptCost <= bcrypt.MaxCost,
+		"bcrypt-cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	u, err := url.Parse(cfg.baseURL)
+	check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && strings.TrimSuffix(u.Path, "/") == "",
+		"base-url must be an http or https URL without a path")
+	switch cfg.mail.mailer {
+	case "log":
+		check(err != nil || u.Scheme != "https", "mailer must be smtp when base-url is https")
+	case "smtp":
+		_, _, err := net.SplitHostPort(cfg.mail.addr)
+		check(err == nil, "smtp-addr must be set as host:port when mailer is smtp")
+	default:
+		check(false, "mailer must be log or smtp")
+	}
+	_, err = mail.ParseAddress(cfg.mail.sender)
+	check(err == nil, "mail-sender must be a valid email address")
+	check(cfg.shutdownDrain >= 0, "shutdown-drain must not be negative")
+	check(cfg.shutdownGrace > 0, "shutdown-grace must be positive")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// print writes the resolved configuration as a JSON config file, with the
+// values of secret settings redacted.
+func (cfg *config) print(w io.Writer) error {
+	settings := map[string]string{}
+	cfg.flags.VisitAll(func(f *flag.Flag) {
+		if loaderSettings[f.Name] {
+			return
+		}
+		settings[f.Name] = redact(f.Name, f.Value.String())
+	})
+
+	js, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", js)
+	return err
+}
+
+// redact hides the password in a secret setting. URLs keep everything but the
+// password in the userinfo and any password query parameter, such as the
+// password and sslpassword that pgx accepts; anything else is hidden whole.
+func redact(name, value string) string {
+	if !secretSettings[name] || value == "" {
+		return value
+	}
+	if u, err := url.Parse(value); err == nil && u.Scheme != "" {
+		query := u.Query()
+		redacted := false
+		for key := range query {
+			if strings.Contains(strings.ToLower(key), "password") {
+				query.Set(key, "xxxxx")
+				redacted = true
+			}
+		}
+		if redacted {
+			u.RawQuery = query.Encode()
+		}
+		return u.Redacted()
+	}
+	return "REDACTED"
+}
@@ -2,4 +2,9 @@ package main
 
 type contextKey string
 
-const isAuthenticatedContextKey = contextKey("isAuthenticated")
+const (
+	isAuthenticatedContextKey     = contextKey("isAuthenticated")
+	authenticatedUserIDContextKey = contextKey("authenticatedUserID")
+	requestIDContextKey           = contextKey("requestID")
+	apiTokenContextKey            = contextKey("apiToken")
+)
@@ -3,21 +3,44 @@ package main
 import (
 	"errors"
 	"fmt"
+	"html/template"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
-	"github.com/julienschmidt/httprouter"
+	"github.com/purple-mountain/snippetbox/internal/diff"
+	"github.com/purple-mountain/snippetbox/internal/highlight"
 	"github.com/purple-mountain/snippetbox/internal/models"
+	"github.com/purple-mountain/snippetbox/internal/totp"
 	"github.com/purple-mountain/snippetbox/internal/validator"
+	"rsc.io/qr"
+)
+
+const (
+	snippetsPerPage       = 10
+	verificationTokenTTL  = 24 * time.Hour
+	passwordResetTokenTTL = time.Hour
+	twoFactorLoginTimeout = 5 * time.Minute
+	totpIssuer            = "Snippetbox"
 )
 
 type snippetCreateForm struct {
 	Content             string `form:"content"`
 	Title               string `form:"title"`
+	Language            string `form:"language"`
 	Expires             int    `form:"expires"`
 	validator.Validator `form:"-"`
 }
 
+type snippetEditForm struct {
+	Content             string `form:"content"`
+	Title               string `form:"title"`
+	Language            string `form:"language"`
+	validator.Validator `form:"-"`
+}
+
 type userSignupForm struct {
 	Name                string `form:"name"`
 	Email               string `form:"email"`
@@ -38,6 +61,34 @@ type userPasswordUpdateForm struct {
 	validator.Validator    `form:"-"`
 }
 
+type userPasswordForgotForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+type userPasswordResetForm struct {
+	Token                  string `form:"token"`
+	NewPassword            string `form:"new-password"`
+	NewPasswordConfimation string `form:"new-password-confirm"`
+	validator.Validator    `form:"-"`
+}
+
+type twoFactorForm struct {
+	Code                string `form:"code"`
+	validator.Validator `form:"-"`
+}
+
+type twoFactorDisableForm struct {
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+type apiTokenForm struct {
+	Name                string `form:"name"`
+	Scope               string `form:"scope"`
+	validator.Validator `form:"-"`
+}
+
 func ping(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
@@ -45,7 +96,7 @@ func ping(w http.ResponseWriter, r *http.Request) {
 func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userSignupForm{}
-	app.render(w, http.StatusOK, "signup.tmpl.html", data)
+	app.render(w, r, http.StatusOK, "signup.tmpl.html", data)
 }
 
 func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
@@ -65,29 +116,78 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 	if !form.IsValid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
 		return
 	}
-	err = app.users.Insert(form.Name, form.Email, form.Password)
+	id, err := app.users.Insert(r.Context(), form.Name, form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.AddFieldError(false, "email", "Email address is already in use")
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
 		} else {
-			app.serverError(w, err)
-			return
+			app.serverError(w, r, err)
 		}
+		return
+	}
+	err = app.sendVerificationEmail(r, id, form.Name, form.Email)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
-	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please log in.")
+	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. We've sent you an email to verify your address. Please log in.")
 	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 }
 
+func (app *application) userVerify(w http.ResponseWriter, r *http.Request) {
+	_, err := app.users.Verify(r.Context(), r.URL.Query().Get("token"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidToken) {
+			data := app.newTemplateData(r)
+			app.render(w, r, http.StatusBadRequest, "verify.tmpl.html", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.sessionManager.Put(r.Context(), "flash", "Your email address has been verified.")
+	if app.isAuthenticated(r) {
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+func (app *application) userVerifyResendPost(w http.ResponseWriter, r *http.Request) {
+	user, err := app.users.GetUser(r.Context(), app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if user.Verified {
+		app.sessionManager.Put(r.Context(), "flash", "Your email address is already verified.")
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return
+	}
+	err = app.tokens.DeleteAllForUser(r.Context(), user.ID, models.ScopeVerification)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = app.sendVerificationEmail(r, user.ID, user.Name, user.Email)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("We've sent a new verification email to %s.", user.Email))
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
 func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userSignupForm{}
-	app.render(w, http.StatusOK, "login.tmpl.html", data)
+	app.render(w, r, http.StatusOK, "login.tmpl.html", data)
 }
 
 func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
@@ -105,39 +205,101 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 	if !form.IsValid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "login.tmpl.html", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl.html", data)
 		return
 	}
-	id, err := app.users.Authenticate(form.Email, form.Password)
+	id, err := app.users.Authenticate(r.Context(), form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
 			form.AddNonFieldError("Email or password is incorrect")
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "login.tmpl.html", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl.html", data)
 		} else {
-			app.serverError(w, err)
-			return
+			app.serverError(w, r, err)
 		}
+		return
+	}
+	user, err := app.users.GetUser(r.Context(), id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 	err = app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
-	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
-	pathBeforeLogin := app.sessionManager.PopString(r.Context(), "redirectPathAfterLogin")
-	if pathBeforeLogin != "" {
-		http.Redirect(w, r, pathBeforeLogin, http.StatusSeeOther)
+	if user.TwoFactor {
+		app.sessionManager.Put(r.Context(), "twoFactorUserID", id)
+		app.sessionManager.Put(r.Context(), "twoFactorStarted", time.Now().Unix())
+		http.Redirect(w, r, "/user/login/2fa", http.StatusSeeOther)
 		return
 	}
-	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
+	app.completeLogin(w, r, id)
+}
+
+func (app *application) userLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if app.twoFactorUserID(r) == 0 {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+	data := app.newTemplateData(r)
+	data.Form = twoFactorForm{}
+	app.render(w, r, http.StatusOK, "login2fa.tmpl.html", data)
+}
+
+func (app *application) userLoginTwoFactorPost(w http.ResponseWriter, r *http.Request) {
+	id := app.twoFactorUserID(r)
+	if id == 0 {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+	if !app.twoFactorLimiter.allow(strconv.Itoa(id)) {
+		w.Header().Set("Retry-After", "60")
+		app.clientError(w, http.StatusTooManyRequests)
+		return
+	}
+
+	var form twoFactorForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.AddFieldError(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
+	if form.IsValid() {
+		err = app.users.VerifySecondFactor(r.Context(), id, form.Code)
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddNonFieldError("The code is incorrect")
+		} else if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	if !form.IsValid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "login2fa.tmpl.html", data)
+		return
+	}
+
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.sessionManager.Remove(r.Context(), "twoFactorUserID")
+	app.sessionManager.Remove(r.Context(), "twoFactorStarted")
+	app.completeLogin(w, r, id)
 }
 
 func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 	err := app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
@@ -148,7 +310,7 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 func (app *application) passwordUpdate(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userPasswordUpdateForm{}
-	app.render(w, http.StatusOK, "password.tmpl.html", data)
+	app.render(w, r, http.StatusOK, "password.tmpl.html", data)
 }
 
 func (app *application) passwordUpdatePost(w http.ResponseWriter, r *http.Request) {
@@ -168,78 +330,436 @@ func (app *application) passwordUpdatePost(w http.ResponseWriter, r *http.Reques
 	if !form.IsValid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "password.tmpl.html", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "password.tmpl.html", data)
 		return
 	}
 
 	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
-	err = app.users.UpdatePassword(id, form.CurrentPassword, form.NewPassword)
+	err = app.users.UpdatePassword(r.Context(), id, form.CurrentPassword, form.NewPassword)
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
 			form.AddNonFieldError("Current Password is incorrect")
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "password.tmpl.html", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "password.tmpl.html", data)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
+	err = app.putSessionEpoch(r, id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 	app.sessionManager.Put(r.Context(), "flash", "Your password has been updated")
 	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
 }
 
+func (app *application) passwordForgot(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userPasswordForgotForm{}
+	app.render(w, r, http.StatusOK, "forgot.tmpl.html", data)
+}
+
+// passwordForgotPost sends a reset link if the address belongs to an account.
+// The response is the same either way so that it cannot be used to find out
+// which addresses are registered.
+func (app *application) passwordForgotPost(w http.ResponseWriter, r *http.Request) {
+	var form userPasswordForgotForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.AddFieldError(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.AddFieldError(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+
+	if !form.IsValid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "forgot.tmpl.html", data)
+		return
+	}
+
+	if app.resetEmailLimiter.allow(strings.ToLower(form.Email)) {
+		app.sendPasswordResetEmail(r, form.Email)
+	}
+	app.sessionManager.Put(r.Context(), "flash", "If an account exists for that email address, we've sent it a link to reset the password.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+func (app *application) passwordReset(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userPasswordResetForm{Token: r.URL.Query().Get("token")}
+	app.render(w, r, http.StatusOK, "reset.tmpl.html", data)
+}
+
+func (app *application) passwordResetPost(w http.ResponseWriter, r *http.Request) {
+	var form userPasswordResetForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.AddFieldError(validator.NotBlank(form.NewPassword), "newPassword", "This field cannot be blank")
+	form.AddFieldError(validator.NotBlank(form.NewPasswordConfimation), "newPasswordConfirm", "This field cannot be blank")
+	form.AddFieldError(validator.MinChars(form.NewPassword, 8), "newPassword", "This field must be at least 8 characters long")
+	form.AddFieldError(validator.IsEqual(form.NewPassword, form.NewPasswordConfimation), "newPasswordConfirm", "Passwords do not match")
+
+	if !form.IsValid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "reset.tmpl.html", data)
+		return
+	}
+
+	_, err = app.users.ResetPassword(r.Context(), form.Token, form.NewPassword)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidToken) {
+			form.AddNonFieldError("This reset link is invalid, has already been used or has expired.")
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "reset.tmpl.html", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been reset. Please log in.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+func (app *application) twoFactorEnable(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.twoFactorCandidate(w, r)
+	if !ok {
+		return
+	}
+	secret := app.sessionManager.GetString(r.Context(), "totpPendingSecret")
+	if secret == "" {
+		var err error
+		secret, err = totp.GenerateSecret()
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		app.sessionManager.Put(r.Context(), "totpPendingSecret", secret)
+	}
+	data := app.newTemplateData(r)
+	data.TwoFactor = &twoFactorSetup{Secret: secret, URI: template.URL(totp.URI(totpIssuer, user.Email, secret))}
+	data.Form = twoFactorForm{}
+	app.render(w, r, http.StatusOK, "enable2fa.tmpl.html", data)
+}
+
+func (app *application) twoFactorQR(w http.ResponseWriter, r *http.Request) {
+	secret := app.sessionManager.GetString(r.Context(), "totpPendingSecret")
+	if secret == "" {
+		app.notFound(w)
+		return
+	}
+	user, err := app.users.GetUser(r.Context(), app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	code, err := qr.Encode(totp.URI(totpIssuer, user.Email, secret), qr.M)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	code.Scale = 6
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(code.PNG())
+}
+
+func (app *application) twoFactorEnablePost(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.twoFactorCandidate(w, r)
+	if !ok {
+		return
+	}
+	secret := app.sessionManager.GetString(r.Context(), "totpPendingSecret")
+	if secret == "" {
+		http.Redirect(w, r, "/account/2fa/enable", http.StatusSeeOther)
+		return
+	}
+
+	var form twoFactorForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.AddFieldError(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+	if form.IsValid() {
+		_, ok := totp.Validate(secret, strings.ReplaceAll(form.Code, " ", ""), time.Now())
+		form.AddFieldError(ok, "code", "The code is incorrect. Check the time on your device and try again")
+	}
+
+	if !form.IsValid() {
+		data := app.newTemplateData(r)
+		data.TwoFactor = &twoFactorSetup{Secret: secret, URI: template.URL(totp.URI(totpIssuer, user.Email, secret))}
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "enable2fa.tmpl.html", data)
+		return
+	}
+
+	codes, err := app.users.EnableTwoFactor(r.Context(), user.ID, secret)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.sessionManager.Remove(r.Context(), "totpPendingSecret")
+	data := app.newTemplateData(r)
+	data.RecoveryCodes = codes
+	app.render(w, r, http.StatusOK, "recovery.tmpl.html", data)
+}
+
+func (app *application) twoFactorDisable(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = twoFactorDisableForm{}
+	app.render(w, r, http.StatusOK, "disable2fa.tmpl.html", data)
+}
+
+func (app *application) twoFactorDisablePost(w http.ResponseWriter, r *http.Request) {
+	var form twoFactorDisableForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.AddFieldError(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	user, err := app.users.GetUser(r.Context(), app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if form.IsValid() {
+		_, err = app.users.Authenticate(r.Context(), user.Email, form.Password)
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldError(false, "password", "Password is incorrect")
+		} else if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	if !form.IsValid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "disable2fa.tmpl.html", data)
+		return
+	}
+
+	err = app.users.DisableTwoFactor(r.Context(), user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.sessionManager.Put(r.Context(), "flash", "Two-factor authentication has been turned off.")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (app *application) accountTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := app.apiTokens.ByUser(r.Context(), app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data := app.newTemplateData(r)
+	data.APITokens = tokens
+	data.Form = apiTokenForm{Scope: models.ScopeRead}
+	app.render(w, r, http.StatusOK, "tokens.tmpl.html", data)
+}
+
+func (app *application) accountTokenCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form apiTokenForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.AddFieldError(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.AddFieldError(validator.LowerThanMaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+	form.AddFieldError(validator.PermittedValue(form.Scope, models.ScopeRead, models.ScopeWrite), "scope", "This field must be read or write")
+
+	userID := app.authenticatedUserID(r)
+	status := http.StatusUnprocessableEntity
+	data := app.newTemplateData(r)
+	if form.IsValid() {
+		data.NewAPIToken, err = app.apiTokens.Insert(r.Context(), userID, form.Name, form.Scope)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		status = http.StatusOK
+		form = apiTokenForm{Scope: models.ScopeRead}
+	}
+
+	data.APITokens, err = app.apiTokens.ByUser(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data.Form = form
+	app.render(w, r, status, "tokens.tmpl.html", data)
+}
+
+func (app *application) accountTokenDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	err = app.apiTokens.Delete(r.Context(), id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.sessionManager.Put(r.Context(), "flash", "The token has been revoked.")
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
+
 func (app *application) about(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
-	app.render(w, http.StatusOK, "about.tmpl.html", data)
+	app.render(w, r, http.StatusOK, "about.tmpl.html", data)
 }
 
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := app.snippets.Latest()
+	q := models.PageQuery{Limit: snippetsPerPage}
+	query := r.URL.Query()
+	if query.Has("before") {
+		cursor, err := models.ParseCursor(query.Get("before"))
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		q.Before = &cursor
+	} else if query.Has("after") {
+		cursor, err := models.ParseCursor(query.Get("after"))
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		q.After = &cursor
+	}
+
+	page, err := app.snippets.Latest(r.Context(), q)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 	data := app.newTemplateData(r)
-	data.Snippets = snippets
-	app.render(w, http.StatusOK, "home.tmpl.html", data)
+	data.Snippets = page.Snippets
+	data.Page = page
+	app.render(w, r, http.StatusOK, "home.tmpl.html", data)
+}
+
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	page := 1
+	if query.Has("page") {
+		var err error
+		page, err = strconv.Atoi(query.Get("page"))
+		if err != nil || page < 1 {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		page = min(page, models.SearchMaxPage)
+	}
+
+	data := app.newTemplateData(r)
+	data.SearchQuery = q
+	if !validator.NotBlank(q) {
+		app.render(w, r, http.StatusOK, "search.tmpl.html", data)
+		return
+	}
+	if !validator.LowerThanMaxChars(q, 200) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	results, err := app.snippets.Search(r.Context(), q, page)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data.SearchResults = results
+	app.render(w, r, http.StatusOK, "search.tmpl.html", data)
 }
 
 func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
 	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
-	user, err := app.users.GetUser(id)
+	user, err := app.users.GetUser(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
+	snippets, err := app.snippets.ByUser(r.Context(), id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 	data := app.newTemplateData(r)
 	data.User = user
-	app.render(w, http.StatusOK, "account.tmpl.html", data)
+	data.Snippets = snippets
+	app.render(w, r, http.StatusOK, "account.tmpl.html", data)
 }
 
 func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
-	params := httprouter.ParamsFromContext(r.Context())
-	id, err := strconv.Atoi(params.ByName("id"))
-	if err != nil || id < 0 {
+	id, err := app.readIDParam(r)
+	if err != nil {
 		app.notFound(w)
 		return
 	}
-	snippet, err := app.snippets.Get(id)
+	snippet, err := app.snippets.Get(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 	data := app.newTemplateData(r)
 	data.Snippet = snippet
-	app.render(w, http.StatusOK, "view.tmpl.html", data)
+	data.ShowSource = r.URL.Query().Has("source")
+	app.render(w, r, http.StatusOK, "view.tmpl.html", data)
+}
+
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.visibleSnippet(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write([]byte(snippet.Content))
+}
+
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.visibleSnippet(w, r)
+	if !ok {
+		return
+	}
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": snippetFilename(snippet)})
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write([]byte(snippet.Content))
 }
 
 func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
@@ -247,7 +767,7 @@ func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	data.Form = snippetCreateForm{
 		Expires: 365,
 	}
-	app.render(w, http.StatusOK, "create.tmpl.html", data)
+	app.render(w, r, http.StatusOK, "create.tmpl.html", data)
 }
 
 func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
@@ -261,18 +781,20 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	form.AddFieldError(validator.NotBlank(form.Title), "title", "This field cannot be blank")
 	form.AddFieldError(validator.LowerThanMaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
 	form.AddFieldError(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.AddFieldError(form.Language == "" || validator.PermittedValue(form.Language, highlight.Names()...), "language", "This field must be a supported language")
 	form.AddFieldError(validator.PermittedValue(form.Expires, 365, 7, 1), "expires", "This field must be equal 1, 7 or 365")
 
 	if !form.IsValid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "create.tmpl.html", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "create.tmpl.html", data)
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	language, confidence := detectLanguage(form.Title, form.Content, form.Language)
+	id, err := app.snippets.Insert(r.Context(), app.authenticatedUserID(r), form.Title, form.Content, language, confidence, form.Expires)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -281,6 +803,232 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
 }
 
-func (app *application) deleteSnippet(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte("Delete a snippet"))
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	form := snippetEditForm{
+		Title:    snippet.Title,
+		Content:  snippet.Content,
+		Language: snippet.Language,
+	}
+	if snippet.LanguageConfidence > 0 {
+		form.Language = ""
+	}
+	data.Form = form
+	app.render(w, r, http.StatusOK, "edit.tmpl.html", data)
+}
+
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetEditForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.AddFieldError(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.AddFieldError(validator.LowerThanMaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.AddFieldError(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.AddFieldError(form.Language == "" || validator.PermittedValue(form.Language, highlight.Names()...), "language", "This field must be a supported language")
+
+	if !form.IsValid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "edit.tmpl.html", data)
+		return
+	}
+
+	unchangedLanguage := form.Language == snippet.Language || (form.Language == "" && snippet.LanguageConfidence > 0)
+	if form.Title == snippet.Title && form.Content == snippet.Content && unchangedLanguage {
+		app.sessionManager.Put(r.Context(), "flash", "No changes to save.")
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+		return
+	}
+
+	language, confidence := detectLanguage(form.Title, form.Content, form.Language)
+	err = app.snippets.Update(r.Context(), snippet.ID, form.Title, form.Content, language, confidence)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully updated!")
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	snippet, err := app.snippets.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	revisions, err := app.snippets.Revisions(r.Context(), id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Revisions = revisions
+	app.render(w, r, http.StatusOK, "history.tmpl.html", data)
+}
+
+func (app *application) snippetRevision(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	version, err := app.readIntParam(r, "version")
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	snippet, err := app.snippets.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	revision, err := app.snippets.Revision(r.Context(), id, version)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Revision = revision
+	app.render(w, r, http.StatusOK, "revision.tmpl.html", data)
+}
+
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	snippet, err := app.snippets.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	query := r.URL.Query()
+	to := snippet.Version
+	if query.Has("to") {
+		to, err = strconv.Atoi(query.Get("to"))
+		if err != nil || to < 1 {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+	from := max(to-1, 1)
+	if query.Has("from") {
+		from, err = strconv.Atoi(query.Get("from"))
+		if err != nil || from < 1 {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	fromRevision, err := app.snippets.Revision(r.Context(), id, from)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	toRevision, err := app.snippets.Revision(r.Context(), id, to)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Diff = &revisionDiff{
+		From:  fromRevision,
+		To:    toRevision,
+		Split: query.Get("view") == "split",
+	}
+	lines, err := diff.Strings(fromRevision.Content, toRevision.Content)
+	switch {
+	case errors.Is(err, diff.ErrTooDifferent):
+		data.Diff.TooDifferent = true
+	case err != nil:
+		app.serverError(w, r, err)
+		return
+	default:
+		data.Diff.Hunks = diff.Unified(lines, 3)
+		data.Diff.Rows = diff.SideBySide(lines)
+	}
+	app.render(w, r, http.StatusOK, "diff.tmpl.html", data)
+}
+
+func (app *application) snippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	app.render(w, r, http.StatusOK, "delete.tmpl.html", data)
+}
+
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+	err := app.snippets.Delete(r.Context(), snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully deleted!")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
 }
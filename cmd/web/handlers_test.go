@@ -4,9 +4,14 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/purple-mountain/snippetbox/internal/assert"
+	"github.com/purple-mountain/snippetbox/internal/mocks"
+	"github.com/purple-mountain/snippetbox/internal/totp"
 )
 
 func TestPing(t *testing.T) {
@@ -50,6 +55,83 @@ func TestSnippetCreate(t *testing.T) {
 		assert.Equal(t, statusCode, http.StatusOK)
 		assert.StringContains(t, body, `<form action="/snippet/create" method="POST">`)
 	})
+	t.Run("Unverified", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t, "bob@example.com", validPassword)
+		statusCode, header, _ := ts.get(t, "/snippet/create")
+		assert.Equal(t, statusCode, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/account/view")
+	})
+}
+
+func TestSnippetCreatePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+	_, _, body := ts.get(t, "/snippet/create")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		language     string
+		expires      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Valid Submission",
+			language:     "go",
+			expires:      "7",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/2",
+		},
+		{
+			name:         "Detect Language",
+			language:     "",
+			expires:      "365",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/2",
+		},
+		{
+			name:         "Plain Text",
+			language:     "text",
+			expires:      "365",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/2",
+		},
+		{
+			name:     "Unknown Language",
+			language: "cobol",
+			expires:  "7",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Invalid Expiry",
+			language: "go",
+			expires:  "30",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Hello")
+			form.Add("content", "package main")
+			form.Add("language", tt.language)
+			form.Add("expires", tt.expires)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, header, _ := ts.postForm(t, "/snippet/create", form)
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantLocation != "" {
+				assert.Equal(t, header.Get("Location"), tt.wantLocation)
+			}
+		})
+	}
 }
 
 func TestUserSignup(t *testing.T) {
@@ -162,6 +244,56 @@ func TestUserSignup(t *testing.T) {
 			}
 		})
 	}
+
+	app.wg.Wait()
+	sent := app.mailer.(*mocks.Mailer).Sent()
+	assert.Equal(t, len(sent), 1)
+	assert.Equal(t, sent[0].To, validEmail)
+	assert.StringContains(t, sent[0].Body, "https://snippetbox.example.com/user/verify?token=valid-token")
+}
+
+func TestUserVerify(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "Valid Token",
+			urlPath:      "/user/verify?token=valid-token",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/user/login",
+		},
+		{
+			name:     "Invalid Token",
+			urlPath:  "/user/verify?token=used-token",
+			wantCode: http.StatusBadRequest,
+			wantBody: "invalid, has already been used or has expired",
+		},
+		{
+			name:     "Missing Token",
+			urlPath:  "/user/verify",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantLocation != "" {
+				assert.Equal(t, header.Get("Location"), tt.wantLocation)
+			}
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
 }
 
 func TestSnippetView(t *testing.T) {
@@ -181,6 +313,36 @@ func TestSnippetView(t *testing.T) {
 			wantCode: http.StatusOK,
 			wantBody: "An old silent pond...",
 		},
+		{
+			name:     "Shows Author",
+			urlPath:  "/snippet/view/1",
+			wantCode: http.StatusOK,
+			wantBody: "by Alice",
+		},
+		{
+			name:     "Anonymous Author",
+			urlPath:  "/snippet/view/4",
+			wantCode: http.StatusOK,
+			wantBody: "by anonymous",
+		},
+		{
+			name:     "Detected Language",
+			urlPath:  "/snippet/view/3",
+			wantCode: http.StatusOK,
+			wantBody: "detected: Markdown",
+		},
+		{
+			name:     "Rendered Markdown",
+			urlPath:  "/snippet/view/3",
+			wantCode: http.StatusOK,
+			wantBody: `<div class="markdown"><p>Over the wintry forest, winds howl in rage...</p>`,
+		},
+		{
+			name:     "Markdown Source",
+			urlPath:  "/snippet/view/3?source",
+			wantCode: http.StatusOK,
+			wantBody: `<pre class="chroma">`,
+		},
 		{
 			name:     "Non-existent ID",
 			urlPath:  "/snippet/view/2",
@@ -218,4 +380,744 @@ func TestSnippetView(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("Anonymous Snippet Not Editable", func(t *testing.T) {
+		_, _, body := ts.get(t, "/snippet/view/4")
+		assert.Equal(t, strings.Contains(body, "/snippet/edit/4"), false)
+	})
+}
+
+func TestSnippetRaw(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Valid ID", func(t *testing.T) {
+		statusCode, header, body := ts.get(t, "/snippet/raw/1")
+		assert.Equal(t, statusCode, http.StatusOK)
+		assert.Equal(t, header.Get("Content-Type"), "text/plain; charset=utf-8")
+		assert.Equal(t, header.Get("X-Content-Type-Options"), "nosniff")
+		assert.Equal(t, body, "An old silent pond...")
+	})
+	t.Run("Non-existent ID", func(t *testing.T) {
+		statusCode, _, _ := ts.get(t, "/snippet/raw/2")
+		assert.Equal(t, statusCode, http.StatusNotFound)
+	})
+}
+
+func TestSnippetDownload(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantDisposition string
+	}{
+		{
+			name:            "Plain Text",
+			urlPath:         "/snippet/download/1",
+			wantCode:        http.StatusOK,
+			wantDisposition: "attachment; filename=an-old-silent-pond.txt",
+		},
+		{
+			name:            "Markdown",
+			urlPath:         "/snippet/download/3",
+			wantCode:        http.StatusOK,
+			wantDisposition: "attachment; filename=over-the-wintry-forest.md",
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/download/2",
+			wantCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statusCode, header, _ := ts.get(t, tt.urlPath)
+			assert.Equal(t, statusCode, tt.wantCode)
+			assert.Equal(t, header.Get("Content-Disposition"), tt.wantDisposition)
+		})
+	}
+}
+
+func TestAccountView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		statusCode, header, _ := ts.get(t, "/account/view")
+		assert.Equal(t, statusCode, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+	})
+	t.Run("Authenticated", func(t *testing.T) {
+		ts.login(t, "alice@example.com", "pa$$word")
+
+		statusCode, _, body := ts.get(t, "/account/view")
+		assert.Equal(t, statusCode, http.StatusOK)
+		assert.StringContains(t, body, "Your Snippets")
+		assert.StringContains(t, body, `<a href="/snippet/view/1">An old silent pond</a>`)
+	})
+}
+
+func TestSnippetDelete(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		statusCode, header, _ := ts.get(t, "/snippet/delete/1")
+		assert.Equal(t, statusCode, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+	})
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	t.Run("Confirm", func(t *testing.T) {
+		statusCode, _, body := ts.get(t, "/snippet/delete/1")
+		assert.Equal(t, statusCode, http.StatusOK)
+		assert.StringContains(t, body, `<form action="/snippet/delete/1" method="POST">`)
+	})
+
+	_, _, body := ts.get(t, "/snippet/delete/1")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		csrfToken    string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Owner",
+			urlPath:      "/snippet/delete/1",
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/account/view",
+		},
+		{
+			name:      "Not Owner",
+			urlPath:   "/snippet/delete/3",
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusForbidden,
+		},
+		{
+			name:      "Non-existent ID",
+			urlPath:   "/snippet/delete/2",
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusNotFound,
+		},
+		{
+			name:      "Invalid CSRF Token",
+			urlPath:   "/snippet/delete/1",
+			csrfToken: "",
+			wantCode:  http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, header, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantLocation != "" {
+				assert.Equal(t, header.Get("Location"), tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestSnippetEdit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		statusCode, header, _ := ts.get(t, "/snippet/edit/1")
+		assert.Equal(t, statusCode, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+	})
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	t.Run("Form", func(t *testing.T) {
+		statusCode, _, body := ts.get(t, "/snippet/edit/1")
+		assert.Equal(t, statusCode, http.StatusOK)
+		assert.StringContains(t, body, `<form action="/snippet/edit/1" method="POST">`)
+		assert.StringContains(t, body, `value="An old silent pond"`)
+	})
+
+	t.Run("Not Owner", func(t *testing.T) {
+		statusCode, _, _ := ts.get(t, "/snippet/edit/3")
+		assert.Equal(t, statusCode, http.StatusForbidden)
+	})
+
+	_, _, body := ts.get(t, "/snippet/edit/1")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		title        string
+		content      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Valid Submission",
+			title:        "An old silent pond",
+			content:      "A frog jumps into the pond, splash! Silence again.",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/1",
+		},
+		{
+			name:         "No Changes",
+			title:        "An old silent pond",
+			content:      "An old silent pond...",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/1",
+		},
+		{
+			name:     "Empty Title",
+			title:    "",
+			content:  "An old silent pond...",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Empty Content",
+			title:    "An old silent pond",
+			content:  "",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, header, _ := ts.postForm(t, "/snippet/edit/1", form)
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantLocation != "" {
+				assert.Equal(t, header.Get("Location"), tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestSnippetHistory(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "History",
+			urlPath:  "/snippet/view/1/history",
+			wantCode: http.StatusOK,
+			wantBody: `<a href="/snippet/view/1/history/1">v1</a>`,
+		},
+		{
+			name:     "History Non-existent ID",
+			urlPath:  "/snippet/view/2/history",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Old Revision",
+			urlPath:  "/snippet/view/1/history/1",
+			wantCode: http.StatusOK,
+			wantBody: "An old pond...",
+		},
+		{
+			name:     "Current Revision",
+			urlPath:  "/snippet/view/1/history/2",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond...",
+		},
+		{
+			name:     "Non-existent Revision",
+			urlPath:  "/snippet/view/1/history/5",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "String Revision",
+			urlPath:  "/snippet/view/1/history/foo",
+			wantCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statusCode, _, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, statusCode, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetDiff(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Default Versions",
+			urlPath:  "/snippet/view/1/diff",
+			wantCode: http.StatusOK,
+			wantBody: "@@ -1,1 &#43;1,1 @@",
+		},
+		{
+			name:     "Unified",
+			urlPath:  "/snippet/view/1/diff?from=1&to=2",
+			wantCode: http.StatusOK,
+			wantBody: `<td class="insert"><pre>An old silent pond...</pre></td>`,
+		},
+		{
+			name:     "Side By Side",
+			urlPath:  "/snippet/view/1/diff?from=1&to=2&view=split",
+			wantCode: http.StatusOK,
+			wantBody: `<td class="delete"><pre>An old pond...</pre></td>`,
+		},
+		{
+			name:     "Same Version",
+			urlPath:  "/snippet/view/1/diff?from=2&to=2",
+			wantCode: http.StatusOK,
+			wantBody: "The content of these versions is identical.",
+		},
+		{
+			name:     "Non-existent Version",
+			urlPath:  "/snippet/view/1/diff?from=1&to=5",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid Version",
+			urlPath:  "/snippet/view/1/diff?from=foo",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/view/2/diff",
+			wantCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statusCode, _, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, statusCode, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHome(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "First Page",
+			urlPath:  "/",
+			wantCode: http.StatusOK,
+			wantBody: `<a href="/snippet/view/1">An old silent pond</a>`,
+		},
+		{
+			name:     "Before Cursor",
+			urlPath:  "/?before=1647512100000000-5",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "After Cursor",
+			urlPath:  "/?after=1647512100000000-5",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Invalid Cursor",
+			urlPath:  "/?before=foo",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statusCode, _, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, statusCode, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Empty Query",
+			urlPath:  "/search",
+			wantCode: http.StatusOK,
+			wantBody: "Type something into the search box",
+		},
+		{
+			name:     "Match",
+			urlPath:  "/search?q=pond",
+			wantCode: http.StatusOK,
+			wantBody: `An old silent <mark>pond</mark>`,
+		},
+		{
+			name:     "No Match",
+			urlPath:  "/search?q=mountain",
+			wantCode: http.StatusOK,
+			wantBody: "No snippets match <strong>mountain</strong>",
+		},
+		{
+			name:     "Escapes Content",
+			urlPath:  "/search?q=%3Cscript%3E",
+			wantCode: http.StatusOK,
+			wantBody: "No snippets match <strong>&lt;script&gt;</strong>",
+		},
+		{
+			name:     "Invalid Page",
+			urlPath:  "/search?q=pond&page=0",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Page Beyond Limit",
+			urlPath:  "/search?q=pond&page=9223372036854775807",
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statusCode, _, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, statusCode, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestPasswordForgotPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/password/forgot")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	forgot := func(email string) (int, http.Header) {
+		form := url.Values{}
+		form.Add("email", email)
+		form.Add("csrf_token", validCSRFToken)
+		code, header, _ := ts.postForm(t, "/user/password/forgot", form)
+		return code, header
+	}
+
+	t.Run("Registered And Unknown Email", func(t *testing.T) {
+		registeredCode, registeredHeader := forgot("alice@example.com")
+		unknownCode, unknownHeader := forgot("nobody@example.com")
+		assert.Equal(t, registeredCode, http.StatusSeeOther)
+		assert.Equal(t, unknownCode, registeredCode)
+		assert.Equal(t, unknownHeader.Get("Location"), registeredHeader.Get("Location"))
+
+		app.wg.Wait()
+		sent := app.mailer.(*mocks.Mailer).Sent()
+		assert.Equal(t, len(sent), 1)
+		assert.Equal(t, sent[0].To, "alice@example.com")
+		assert.StringContains(t, sent[0].Body, "https://snippetbox.example.com/user/password/reset?token=valid-token")
+	})
+	t.Run("Invalid Email", func(t *testing.T) {
+		code, _ := forgot("alice@")
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	})
+	t.Run("Rate Limited", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			code, _ := forgot("alice@example.com")
+			assert.Equal(t, code, http.StatusSeeOther)
+		}
+		code, header := forgot("alice@example.com")
+		assert.Equal(t, code, http.StatusTooManyRequests)
+		assert.Equal(t, header.Get("Retry-After"), "60")
+	})
+}
+
+func TestPasswordResetPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	other := newTestServer(t, app.routes())
+	defer other.Close()
+	other.login(t, "alice@example.com", "pa$$word")
+
+	_, _, body := ts.get(t, "/user/password/reset?token=valid-token")
+	assert.StringContains(t, body, `<input type="hidden" name="token" value="valid-token" />`)
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name            string
+		token           string
+		newPassword     string
+		confirmPassword string
+		wantCode        int
+		wantBody        string
+	}{
+		{
+			name:            "Passwords Do Not Match",
+			token:           "valid-token",
+			newPassword:     "new-pa$$word",
+			confirmPassword: "other-pa$$word",
+			wantCode:        http.StatusUnprocessableEntity,
+			wantBody:        "Passwords do not match",
+		},
+		{
+			name:            "Invalid Token",
+			token:           "used-token",
+			newPassword:     "new-pa$$word",
+			confirmPassword: "new-pa$$word",
+			wantCode:        http.StatusUnprocessableEntity,
+			wantBody:        "This reset link is invalid",
+		},
+		{
+			name:            "Valid Token",
+			token:           "valid-token",
+			newPassword:     "new-pa$$word",
+			confirmPassword: "new-pa$$word",
+			wantCode:        http.StatusSeeOther,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("token", tt.token)
+			form.Add("new-password", tt.newPassword)
+			form.Add("new-password-confirm", tt.confirmPassword)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, body := ts.postForm(t, "/user/password/reset", form)
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Other Sessions Logged Out", func(t *testing.T) {
+		code, header, _ := other.get(t, "/account/view")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+	})
+}
+
+func TestUserLoginPost(t *testing.T) {
+	app := newTestApplication(t)
+
+	t.Run("Invalid Credentials", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		_, _, body := ts.get(t, "/user/login")
+		form := url.Values{}
+		form.Add("email", "alice@example.com")
+		form.Add("password", "wrong")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		code, header, body := ts.postForm(t, "/user/login", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.Equal(t, header.Get("Location"), "")
+		assert.StringContains(t, body, "Email or password is incorrect")
+	})
+	t.Run("No Pending Second Factor", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, header, _ := ts.get(t, "/user/login/2fa")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+	})
+	t.Run("Two Factor", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		_, _, body := ts.get(t, "/user/login")
+		form := url.Values{}
+		form.Add("email", "carol@example.com")
+		form.Add("password", "pa$$word")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		code, header, _ := ts.postForm(t, "/user/login", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login/2fa")
+
+		code, _, _ = ts.get(t, "/account/view")
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		code, _, body = ts.get(t, "/user/login/2fa")
+		assert.Equal(t, code, http.StatusOK)
+		validCSRFToken := extractCSRFToken(t, body)
+
+		tests := []struct {
+			name         string
+			code         string
+			wantCode     int
+			wantLocation string
+		}{
+			{name: "Blank Code", code: "", wantCode: http.StatusUnprocessableEntity},
+			{name: "Wrong Code", code: "000000", wantCode: http.StatusUnprocessableEntity},
+			{name: "Recovery Code", code: "AAAA-BBBB-CCCC-DDDD", wantCode: http.StatusSeeOther, wantLocation: "/account/view"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				form := url.Values{}
+				form.Add("code", tt.code)
+				form.Add("csrf_token", validCSRFToken)
+				code, header, _ := ts.postForm(t, "/user/login/2fa", form)
+				assert.Equal(t, code, tt.wantCode)
+				assert.Equal(t, header.Get("Location"), tt.wantLocation)
+			})
+		}
+
+		code, _, _ = ts.get(t, "/account/view")
+		assert.Equal(t, code, http.StatusOK)
+	})
+}
+
+var totpSecretRX = regexp.MustCompile(`enter this key instead: <code>([A-Z2-7]+)</code>`)
+
+func TestTwoFactorEnable(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+	code, _, body := ts.get(t, "/account/2fa/enable")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `<a href="otpauth://totp/Snippetbox:alice@example.com?`)
+	matches := totpSecretRX.FindStringSubmatch(body)
+	if matches == nil {
+		t.Fatal("no secret found in body")
+	}
+	secret := matches[1]
+	validCSRFToken := extractCSRFToken(t, body)
+
+	code, header, _ := ts.get(t, "/account/2fa/qr")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "image/png")
+
+	_, _, body = ts.get(t, "/account/2fa/enable")
+	assert.StringContains(t, body, secret)
+
+	validCode, err := totp.Code(secret, totp.Step(time.Now()))
+	assert.NilError(t, err)
+	wrongCode, err := totp.Code(secret, totp.Step(time.Now())+10)
+	assert.NilError(t, err)
+
+	form := url.Values{}
+	form.Add("code", wrongCode)
+	form.Add("csrf_token", validCSRFToken)
+	code, _, _ = ts.postForm(t, "/account/2fa/enable", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	form.Set("code", validCode)
+	code, _, body = ts.postForm(t, "/account/2fa/enable", form)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<li><code>AAAA-BBBB-CCCC-DDDD</code></li>")
+
+	code, _, _ = ts.get(t, "/account/2fa/qr")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestAccountTokens(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+	code, _, body := ts.get(t, "/account/tokens")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "CI")
+	assert.StringContains(t, body, "Backups")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("name", "")
+	form.Add("scope", "admin")
+	form.Add("csrf_token", validCSRFToken)
+	code, _, body = ts.postForm(t, "/account/tokens", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field cannot be blank")
+	assert.StringContains(t, body, "This field must be read or write")
+
+	form.Set("name", "Deploy script")
+	form.Set("scope", "write")
+	code, _, body = ts.postForm(t, "/account/tokens", form)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "sbx_new")
+
+	form = url.Values{}
+	form.Add("csrf_token", validCSRFToken)
+	code, header, _ := ts.postForm(t, "/account/tokens/delete/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/account/tokens")
+
+	code, _, _ = ts.postForm(t, "/account/tokens/delete/3", form)
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestPasswordUpdatePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	other := newTestServer(t, app.routes())
+	defer other.Close()
+	other.login(t, "alice@example.com", "pa$$word")
+
+	ts.login(t, "alice@example.com", "pa$$word")
+	_, _, body := ts.get(t, "/account/password/update")
+
+	form := url.Values{}
+	form.Add("current-password", "pa$$word")
+	form.Add("new-password", "new-pa$$word")
+	form.Add("new-password-confirm", "new-pa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, header, _ := ts.postForm(t, "/account/password/update", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/account/view")
+
+	code, _, _ = ts.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+	code, header, _ = other.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/purple-mountain/snippetbox/internal/migrations"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// componentStatus carries only the outcome of a check. /readyz is served
+// without authentication, so failure details go to the log instead.
+type componentStatus struct {
+	Status string `json:"status"`
+}
+
+type readinessReport struct {
+	Status     string                     `json:"status"`
+	Components map[string]componentStatus `json:"components"`
+}
+
+func databaseChecks(db *pgxpool.Pool) []healthCheck {
+	return []healthCheck{
+		{name: "database", check: db.Ping},
+		{name: "migrations", check: func(ctx context.Context) error {
+			pending, err := migrations.Pending(ctx, db)
+			if err != nil {
+				return err
+			}
+			if len(pending) > 0 {
+				return fmt.Errorf("%d pending, next is %04d_%s", len(pending), pending[0].Version, pending[0].Name)
+			}
+			return nil
+		}},
+	}
+}
+
+func (app *application) healthz(w http.ResponseWriter, r *http.Request) {
+	app.writeJSON(w, r, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (app *application) readyz(w http.ResponseWriter, r *http.Request) {
+	report := readinessReport{Status: "ok", Components: map[string]componentStatus{}}
+
+	if app.shuttingDown.Load() {
+		report.Status = "unavailable"
+		report.Components["server"] = componentStatus{Status: "degraded"}
+	} else {
+		report.Components["server"] = componentStatus{Status: "ok"}
+	}
+
+	for _, hc := range app.healthChecks {
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		err := hc.check(ctx)
+		cancel()
+		if err != nil {
+			app.logger.Warn("readiness check failed", "component", hc.name, "error", err.Error())
+			report.Status = "unavailable"
+			report.Components[hc.name] = componentStatus{Status: "degraded"}
+			continue
+		}
+		report.Components[hc.name] = componentStatus{Status: "ok"}
+	}
+
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	app.writeJSON(w, r, status, report)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/purple-mountain/snippetbox/internal/assert"
+)
+
+func TestHealthz(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	statusCode, header, body := ts.get(t, "/healthz")
+	assert.Equal(t, statusCode, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "application/json")
+	assert.Equal(t, body, "{\"status\":\"ok\"}\n")
+}
+
+func TestReadyz(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	down := func(ctx context.Context) error { return errors.New("connection refused") }
+
+	tests := []struct {
+		name           string
+		checks         []healthCheck
+		shuttingDown   bool
+		wantCode       int
+		wantStatus     string
+		wantComponents map[string]componentStatus
+	}{
+		{
+			name:       "Ready",
+			checks:     []healthCheck{{name: "database", check: ok}, {name: "migrations", check: ok}},
+			wantCode:   http.StatusOK,
+			wantStatus: "ok",
+			wantComponents: map[string]componentStatus{
+				"server":     {Status: "ok"},
+				"database":   {Status: "ok"},
+				"migrations": {Status: "ok"},
+			},
+		},
+		{
+			name:       "Database Down",
+			checks:     []healthCheck{{name: "database", check: down}, {name: "migrations", check: ok}},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "unavailable",
+			wantComponents: map[string]componentStatus{
+				"server":     {Status: "ok"},
+				"database":   {Status: "degraded"},
+				"migrations": {Status: "ok"},
+			},
+		},
+		{
+			name:         "Shutting Down",
+			checks:       []healthCheck{{name: "database", check: ok}},
+			shuttingDown: true,
+			wantCode:     http.StatusServiceUnavailable,
+			wantStatus:   "unavailable",
+			wantComponents: map[string]componentStatus{
+				"server":   {Status: "degraded"},
+				"database": {Status: "ok"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.healthChecks = tt.checks
+			app.shuttingDown.Store(tt.shuttingDown)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			statusCode, _, body := ts.get(t, "/readyz")
+			assert.Equal(t, statusCode, tt.wantCode)
+			assert.Equal(t, strings.Contains(body, "connection refused"), false)
+
+			var report readinessReport
+			err := json.Unmarshal([]byte(body), &report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, report.Status, tt.wantStatus)
+			assert.Equal(t, len(report.Components), len(tt.wantComponents))
+			for name, want := range tt.wantComponents {
+				assert.Equal(t, report.Components[name], want)
+			}
+		})
+	}
+}
@@ -2,16 +2,47 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"regexp"
 	"runtime/debug"
+	"strconv"
+	"strings"
+	"text/template"
 	"time"
 
 	"github.com/go-playground/form/v4"
+	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/nosurf"
+	"github.com/purple-mountain/snippetbox/internal/highlight"
+	"github.com/purple-mountain/snippetbox/internal/mailer"
+	"github.com/purple-mountain/snippetbox/internal/models"
+	"github.com/purple-mountain/snippetbox/ui"
+	"go.opentelemetry.io/otel/trace"
 )
 
+func requestID(r *http.Request) string {
+	id, ok := r.Context().Value(requestIDContextKey).(string)
+	if !ok {
+		return ""
+	}
+	return id
+}
+
+func (app *application) requestLogger(r *http.Request) *slog.Logger {
+	logger := app.logger.With("request_id", requestID(r), "method", r.Method, "uri", r.URL.RequestURI())
+	if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+		logger = logger.With("trace_id", sc.TraceID().String())
+	}
+	return logger
+}
+
 func (app *application) isAuthenticated(r *http.Request) bool {
 	isAuthenticated, ok := r.Context().Value(isAuthenticatedContextKey).(bool)
 	if !ok {
@@ -20,6 +51,258 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 	return isAuthenticated
 }
 
+func (app *application) authenticatedUserID(r *http.Request) int {
+	id, ok := r.Context().Value(authenticatedUserIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+	return id
+}
+
+// completeLogin signs the user in once every factor has been checked and the
+// session token renewed.
+func (app *application) completeLogin(w http.ResponseWriter, r *http.Request, id int) {
+	err := app.putSessionEpoch(r, id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+	pathBeforeLogin := app.sessionManager.PopString(r.Context(), "redirectPathAfterLogin")
+	if pathBeforeLogin != "" {
+		http.Redirect(w, r, pathBeforeLogin, http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
+}
+
+// twoFactorUserID returns the user who has passed the password step of the
+// login and still has to enter a second factor, or 0 if there is none or the
+// step has timed out.
+func (app *application) twoFactorUserID(r *http.Request) int {
+	started := app.sessionManager.GetInt64(r.Context(), "twoFactorStarted")
+	if time.Since(time.Unix(started, 0)) > twoFactorLoginTimeout {
+		return 0
+	}
+	return app.sessionManager.GetInt(r.Context(), "twoFactorUserID")
+}
+
+// twoFactorCandidate returns the authenticated user, who must not have
+// two-factor authentication enabled yet.
+func (app *application) twoFactorCandidate(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	user, err := app.users.GetUser(r.Context(), app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, false
+	}
+	if user.TwoFactor {
+		app.sessionManager.Put(r.Context(), "flash", "Two-factor authentication is already turned on.")
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return nil, false
+	}
+	return user, true
+}
+
+// bearerToken returns the API token in the Authorization header of r, or nil
+// if there is none. A header that is not a known bearer token gives
+// models.ErrInvalidToken.
+func (app *application) bearerToken(r *http.Request) (*models.APIToken, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, nil
+	}
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return nil, models.ErrInvalidToken
+	}
+	return app.apiTokens.Authenticate(r.Context(), strings.TrimSpace(token))
+}
+
+// tokenAllows reports whether the scope of the token covers the method of r.
+func tokenAllows(token *models.APIToken, r *http.Request) bool {
+	return token.Scope == models.ScopeWrite || r.Method == http.MethodGet || r.Method == http.MethodHead
+}
+
+func withAPIToken(r *http.Request, token *models.APIToken) *http.Request {
+	ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+	ctx = context.WithValue(ctx, authenticatedUserIDContextKey, token.UserID)
+	ctx = context.WithValue(ctx, apiTokenContextKey, token)
+	return r.WithContext(ctx)
+}
+
+func (app *application) readIDParam(r *http.Request) (int, error) {
+	return app.readIntParam(r, "id")
+}
+
+func (app *application) readIntParam(r *http.Request, name string) (int, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	n, err := strconv.Atoi(params.ByName(name))
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid %s parameter", name)
+	}
+	return n, nil
+}
+
+func (app *application) visibleSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFound(w)
+		return nil, false
+	}
+	snippet, err := app.snippets.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+	return snippet, true
+}
+
+func (app *application) ownedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.visibleSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+	if snippet.UserID != app.authenticatedUserID(r) {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+	return snippet, true
+}
+
+var nonSlugRX = regexp.MustCompile(`[^a-z0-9._]+`)
+
+func snippetFilename(snippet *models.Snippet) string {
+	ext := ".txt"
+	if l, ok := highlight.Lookup(snippet.Language); ok {
+		ext = l.Extension
+	}
+	slug := nonSlugRX.ReplaceAllString(strings.ToLower(snippet.Title), "-")
+	slug = strings.Trim(slug, "-.")
+	if len(slug) > 64 {
+		slug = strings.TrimRight(slug[:64], "-.")
+	}
+	if slug == "" {
+		slug = fmt.Sprintf("snippet-%d", snippet.ID)
+	}
+	if strings.HasSuffix(slug, ext) {
+		return slug
+	}
+	return slug + ext
+}
+
+func detectLanguage(title, content, language string) (string, float64) {
+	if language != "" {
+		return language, 0
+	}
+	d := highlight.Detect(title, content)
+	return d.Language, d.Confidence
+}
+
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Error(fmt.Sprintf("%s", err), "trace", string(debug.Stack()))
+			}
+		}()
+		fn()
+	}()
+}
+
+// sendMail renders the "subject" and "body" templates of the named file in
+// ui/email and sends the message in the background.
+func (app *application) sendMail(r *http.Request, to, name string, data any) error {
+	ts, err := template.ParseFS(ui.Files, "email/"+name)
+	if err != nil {
+		return err
+	}
+	var subject, body strings.Builder
+	err = ts.ExecuteTemplate(&subject, "subject", data)
+	if err != nil {
+		return err
+	}
+	err = ts.ExecuteTemplate(&body, "body", data)
+	if err != nil {
+		return err
+	}
+
+	msg := mailer.Message{To: to, Subject: subject.String(), Body: body.String()}
+	logger := app.requestLogger(r)
+	app.background(func() {
+		err := app.mailer.Send(msg)
+		if err != nil {
+			logger.Error("sending email failed", "to", to, "error", err.Error())
+		}
+	})
+	return nil
+}
+
+func (app *application) sendVerificationEmail(r *http.Request, userID int, name, email string) error {
+	token, err := app.tokens.New(r.Context(), userID, verificationTokenTTL, models.ScopeVerification)
+	if err != nil {
+		return err
+	}
+	data := map[string]string{
+		"Name": name,
+		"URL":  app.baseURL + "/user/verify?token=" + url.QueryEscape(token),
+	}
+	return app.sendMail(r, email, "verification.tmpl", data)
+}
+
+// sendPasswordResetEmail looks the account up and mails it a reset link in the
+// background, so that the response takes as long whether or not the email
+// address is registered.
+func (app *application) sendPasswordResetEmail(r *http.Request, email string) {
+	r = r.WithContext(context.WithoutCancel(r.Context()))
+	app.background(func() {
+		err := app.mailPasswordReset(r, email)
+		if err != nil {
+			app.requestLogger(r).Error("sending password reset email failed", "error", err.Error())
+		}
+	})
+}
+
+func (app *application) mailPasswordReset(r *http.Request, email string) error {
+	user, err := app.users.GetByEmail(r.Context(), email)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil
+		}
+		return err
+	}
+	err = app.tokens.DeleteAllForUser(r.Context(), user.ID, models.ScopePasswordReset)
+	if err != nil {
+		return err
+	}
+	token, err := app.tokens.New(r.Context(), user.ID, passwordResetTokenTTL, models.ScopePasswordReset)
+	if err != nil {
+		return err
+	}
+	data := map[string]string{
+		"Name": user.Name,
+		"URL":  app.baseURL + "/user/password/reset?token=" + url.QueryEscape(token),
+	}
+	return app.sendMail(r, user.Email, "password_reset.tmpl", data)
+}
+
+// putSessionEpoch stamps the session with the current session epoch of the
+// user. authenticate only accepts sessions with a matching stamp, so bumping
+// the epoch logs the user out everywhere else.
+func (app *application) putSessionEpoch(r *http.Request, userID int) error {
+	epoch, err := app.users.SessionEpoch(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+	app.sessionManager.Put(r.Context(), "sessionEpoch", epoch)
+	return nil
+}
+
 func (app *application) decodePostForm(r *http.Request, dst any) error {
 	err := r.ParseForm()
 	if err != nil {
@@ -37,46 +320,138 @@ func (app *application) decodePostForm(r *http.Request, dst any) error {
 	return nil
 }
 
-func (app *application) serverError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-	app.errorLog.Output(2, trace)
-	if app.debugMode {
-		http.Error(w, trace, http.StatusInternalServerError)
+func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	status, message := app.logServerError(r, err)
+	switch status {
+	case 0:
 		return
+	case http.StatusServiceUnavailable:
+		w.Header().Set("Retry-After", "5")
 	}
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	http.Error(w, message, status)
+}
+
+// logServerError logs err and returns the status and message to respond with.
+// The status is 0 if the client has gone away and there is no one to respond
+// to.
+func (app *application) logServerError(r *http.Request, err error) (int, string) {
+	logger := app.requestLogger(r)
+	switch {
+	case errors.Is(err, context.Canceled):
+		logger.Info("request canceled", "error", err.Error())
+		return 0, ""
+	case models.IsTimeout(err):
+		logger.Error(err.Error())
+		return http.StatusGatewayTimeout, "The database took too long to respond. Please try again."
+	case models.IsUnavailable(err):
+		logger.Error(err.Error())
+		return http.StatusServiceUnavailable, "The database is temporarily unavailable. Please try again shortly."
+	}
+
+	trace := string(debug.Stack())
+	logger.Error(err.Error(), "trace", trace)
+	if app.debugMode {
+		return http.StatusInternalServerError, fmt.Sprintf("%s\n%s", err.Error(), trace)
+	}
+	return http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)
 }
 
 func (app *application) clientError(w http.ResponseWriter, status int) {
 	http.Error(w, http.StatusText(status), status)
 }
 
+func (app *application) invalidToken(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	app.clientError(w, http.StatusUnauthorized)
+}
+
 func (app *application) notFound(w http.ResponseWriter) {
 	app.clientError(w, http.StatusNotFound)
 }
 
-func (app *application) render(w http.ResponseWriter, status int, page string, data *templateData) {
+func (app *application) render(w http.ResponseWriter, r *http.Request, status int, page string, data *templateData) {
+	_, span := app.tracer.Start(r.Context(), "render "+page)
+	defer span.End()
+
 	ts, ok := app.templateCache[page]
 	if !ok {
 		err := fmt.Errorf("the template %s does not exist", page)
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 	buffer := new(bytes.Buffer)
 	err := ts.ExecuteTemplate(buffer, "base", data)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 	w.WriteHeader(status)
 	buffer.WriteTo(w)
 }
 
+func (app *application) writeJSON(w http.ResponseWriter, r *http.Request, status int, data any) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(status)
+	w.Write(js)
+	w.Write([]byte("\n"))
+}
+
+// readJSON decodes a single JSON value from the request body into dst. The
+// errors it returns are safe to show to the client.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var unmarshalTypeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
+		var invalidUnmarshalError *json.InvalidUnmarshalError
+		switch {
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains badly-formed JSON")
+		case errors.As(err, &unmarshalTypeError):
+			if unmarshalTypeError.Field != "" {
+				return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+			}
+			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return fmt.Errorf("body contains unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+		case errors.As(err, &maxBytesError):
+			return maxBytesError
+		case errors.As(err, &invalidUnmarshalError):
+			panic(err)
+		default:
+			return err
+		}
+	}
+
+	err = dec.Decode(&struct{}{})
+	if !errors.Is(err, io.EOF) {
+		return errors.New("body must only contain a single JSON value")
+	}
+	return nil
+}
+
 func (app *application) newTemplateData(r *http.Request) *templateData {
 	return &templateData{
-		CurrentYear:     time.Now().Year(),
-		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
-		IsAuthenticated: app.isAuthenticated(r),
-		CSRFToken:       nosurf.Token(r),
+		CurrentYear:         time.Now().Year(),
+		Flash:               app.sessionManager.PopString(r.Context(), "flash"),
+		IsAuthenticated:     app.isAuthenticated(r),
+		AuthenticatedUserID: app.authenticatedUserID(r),
+		CSRFToken:           nosurf.Token(r),
 	}
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/purple-mountain/snippetbox/internal/assert"
+	"github.com/purple-mountain/snippetbox/internal/models"
+)
+
+func TestSnippetFilename(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet *models.Snippet
+		want    string
+	}{
+		{
+			name:    "Title And Language",
+			snippet: &models.Snippet{ID: 1, Title: "Hello, World!", Language: "go"},
+			want:    "hello-world.go",
+		},
+		{
+			name:    "Title With Extension",
+			snippet: &models.Snippet{ID: 1, Title: "main.go", Language: "go"},
+			want:    "main.go",
+		},
+		{
+			name:    "No Language",
+			snippet: &models.Snippet{ID: 1, Title: "Notes", Language: ""},
+			want:    "notes.txt",
+		},
+		{
+			name:    "Unsafe Characters",
+			snippet: &models.Snippet{ID: 1, Title: `../"quoted"/path`, Language: "bash"},
+			want:    "quoted-path.sh",
+		},
+		{
+			name:    "No Usable Title",
+			snippet: &models.Snippet{ID: 7, Title: "日本語", Language: "python"},
+			want:    "snippet-7.py",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, snippetFilename(tt.snippet), tt.want)
+		})
+	}
+}
+
+func TestServerError(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+	}{
+		{
+			name:     "Unexpected Error",
+			err:      errors.New("boom"),
+			wantCode: http.StatusInternalServerError,
+		},
+		{
+			name:     "Query Timeout",
+			err:      fmt.Errorf("models: %w", context.DeadlineExceeded),
+			wantCode: http.StatusGatewayTimeout,
+		},
+		{
+			name:     "Database Starting Up",
+			err:      &pgconn.PgError{Code: models.CannotConnectNow},
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "Client Disconnected",
+			err:      context.Canceled,
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			app.serverError(rr, r, tt.err)
+			assert.Equal(t, rr.Code, tt.wantCode)
+		})
+	}
+}
+
+func TestBackground(t *testing.T) {
+	app := newTestApplication(t)
+
+	done := false
+	app.background(func() {
+		done = true
+	})
+	app.background(func() {
+		panic("boom")
+	})
+	app.wg.Wait()
+
+	assert.Equal(t, done, true)
+}
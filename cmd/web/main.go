@@ -2,82 +2,256 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"html/template"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/alexedwards/scs/pgxstore"
 	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
 	"github.com/go-playground/form/v4"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/purple-mountain/snippetbox/internal/mailer"
 	"github.com/purple-mountain/snippetbox/internal/models"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
 )
 
 type application struct {
-	errorLog       *log.Logger
-	infoLog        *log.Logger
+	logger         *slog.Logger
 	snippets       models.SnippetModelInterface
 	users          models.UserModelInterface
+	tokens         models.TokenModelInterface
+	apiTokens      models.APITokenModelInterface
+	mailer         mailer.Mailer
+	baseURL        string
+	clientIPHeader string
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
 	debugMode      bool
+	metrics        *metrics
+	tracer         trace.Tracer
+	wg             sync.WaitGroup
+	healthChecks   []healthCheck
+	shuttingDown   atomic.Bool
+
+	resetLimiter      *rateLimiter
+	resetEmailLimiter *rateLimiter
+	twoFactorLimiter  *rateLimiter
 }
 
 func main() {
-	addr := flag.String("addr", ":8080", "HTTP network address")
-	dbg := flag.Bool("debug", false, "Enable debug mode")
-	flag.Parse()
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+	cfg, args, err := loadConfig(os.Args[1:], os.Environ())
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if cfg.printConfig {
+		err = cfg.print(os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	logger, err := newLogger(os.Stdout, cfg.logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	tracerProvider, shutdownTracing, err := newTracerProvider(context.Background(), cfg.trace)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	tracer := tracerProvider.Tracer(tracerName)
+
+	metrics := newMetrics()
+	cfg.db.tracer = &queryTracer{metrics: metrics, tracer: tracer}
 
-	db, err := openDB()
+	db, err := openDB(cfg.dsn, cfg.db)
 	if err != nil {
-		errorLog.Fatal(err)
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	if len(args) > 0 && args[0] == "migrate" {
+		err = runMigrate(context.Background(), db, args[1:], logger)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if cfg.migrate {
+		err = runMigrate(context.Background(), db, []string{"up"}, logger)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
 	}
-	defer db.Close(context.Background())
 
 	templateCache, err := newTemplateCache()
 	if err != nil {
-		errorLog.Fatal(err)
+		logger.Error(err.Error())
+		os.Exit(1)
 	}
 	formDecoder := form.NewDecoder()
 
 	sessionManager := scs.New()
-	sessionManager.Lifetime = 12 * time.Hour
+	sessionManager.Store = newSessionStore(cfg.sessionStore, db, cfg.sessionCleanup)
+	sessionManager.Lifetime = cfg.sessionLifetime
 	sessionManager.Cookie.Secure = true
 
+	metrics.registerPool(db)
+	metrics.registerSessions(sessionManager.Store, db)
+
 	app := &application{
-		errorLog:       errorLog,
-		infoLog:        infoLog,
-		snippets:       &models.SnippetModel{DB: db},
-		users:          &models.UserModel{DB: db},
+		logger:         logger,
+		snippets:       &models.SnippetModel{DB: db, Timeout: cfg.queryTimeout},
+		users:          &models.UserModel{DB: db, Timeout: cfg.queryTimeout, BcryptCost: cfg.bcryptCost},
+		tokens:         &models.TokenModel{DB: db, Timeout: cfg.queryTimeout},
+		apiTokens:      &models.APITokenModel{DB: db, Timeout: cfg.queryTimeout},
+		mailer:         newMailer(cfg.mail, logger),
+		baseURL:        strings.TrimSuffix(cfg.baseURL, "/"),
+		clientIPHeader: cfg.clientIPHeader,
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
-		debugMode:      *dbg,
+		debugMode:      cfg.debug,
+		metrics:        metrics,
+		tracer:         tracer,
+		healthChecks:   databaseChecks(db),
+
+		resetLimiter:      newRateLimiter(rate.Every(time.Minute), 5),
+		resetEmailLimiter: newRateLimiter(rate.Every(15*time.Minute), 3),
+		twoFactorLimiter:  newRateLimiter(rate.Every(time.Minute), 5),
 	}
 
 	srv := http.Server{
-		Addr:         *addr,
-		ErrorLog:     errorLog,
+		Addr:         cfg.addr,
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
 		Handler:      app.routes(),
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  cfg.idleTimeout,
+		ReadTimeout:  cfg.readTimeout,
+		WriteTimeout: cfg.writeTimeout,
+	}
+
+	var admin *http.Server
+	if cfg.adminAddr != "" {
+		admin = &http.Server{
+			Addr:         cfg.adminAddr,
+			ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
+			Handler:      app.adminRoutes(),
+			IdleTimeout:  cfg.idleTimeout,
+			ReadTimeout:  cfg.readTimeout,
+			WriteTimeout: cfg.writeTimeout,
+		}
+	}
+
+	err = app.serve(&srv, admin, cfg.shutdownDrain, cfg.shutdownGrace)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+
+	logger.Info("stopping the session cleanup")
+	if store, ok := sessionManager.Store.(interface{ StopCleanup() }); ok {
+		store.StopCleanup()
+	}
+	logger.Info("flushing traces")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error(err.Error())
+	}
+	logger.Info("closing the database pool")
+	db.Close()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+func newLogger(w io.Writer, format string) (*slog.Logger, error) {
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, nil)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, nil)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+// newSessionStore returns the session store of the given kind. Both kinds
+// delete expired sessions in a background goroutine every cleanupInterval.
+func newSessionStore(kind string, db *pgxpool.Pool, cleanupInterval time.Duration) scs.Store {
+	if kind == "postgres" {
+		return pgxstore.NewWithCleanupInterval(db, cleanupInterval)
+	}
+	return memstore.NewWithCleanupInterval(cleanupInterval)
+}
+
+func newMailer(cfg mailConfig, logger *slog.Logger) mailer.Mailer {
+	if cfg.mailer == "smtp" {
+		return &mailer.SMTP{
+			Addr:     cfg.addr,
+			Username: cfg.username,
+			Password: cfg.password,
+			Sender:   cfg.sender,
+		}
 	}
+	return &mailer.Log{Logger: logger}
+}
 
-	infoLog.Printf("The server is running on http://localhost%s", *addr)
-	err = srv.ListenAndServe()
-	errorLog.Fatal(err)
+type poolConfig struct {
+	maxConns          int
+	minConns          int
+	maxConnLifetime   time.Duration
+	maxConnIdleTime   time.Duration
+	healthCheckPeriod time.Duration
+	tracer            pgx.QueryTracer
 }
 
-func openDB() (*pgx.Conn, error) {
-	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+func openDB(dsn string, cfg poolConfig) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	config.MaxConns = int32(cfg.maxConns)
+	config.MinConns = int32(cfg.minConns)
+	config.MaxConnLifetime = cfg.maxConnLifetime
+	config.MaxConnIdleTime = cfg.maxConnIdleTime
+	config.HealthCheckPeriod = cfg.healthCheckPeriod
+	config.ConnConfig.Tracer = cfg.tracer
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	err = pool.Ping(ctx)
 	if err != nil {
+		pool.Close()
 		return nil, err
 	}
-	return conn, nil
+	return pool, nil
 }
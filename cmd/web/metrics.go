@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alexedwards/scs/pgxstore"
+	"github.com/alexedwards/scs/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/purple-mountain/snippetbox/internal/models"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// sessionCountTimeout bounds the query behind the sessions gauge.
+const sessionCountTimeout = 2 * time.Second
+
+type metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	queryDuration   *prometheus.HistogramVec
+	panics          prometheus.Counter
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "snippetbox_http_requests_total",
+			Help: "HTTP requests by route pattern, method and status code.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "snippetbox_http_request_duration_seconds",
+			Help:    "HTTP request latency by route pattern, method and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "snippetbox_db_query_duration_seconds",
+			Help:    "Database query latency by model method and outcome.",
+			Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"method", "outcome"}),
+		panics: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "snippetbox_panics_total",
+			Help: "Panics recovered while serving HTTP requests.",
+		}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests, m.requestDuration, m.queryDuration, m.panics,
+	)
+	return m
+}
+
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *metrics) registerPool(pool *pgxpool.Pool) {
+	m.registry.MustRegister(&poolCollector{pool: pool})
+}
+
+// registerSessions exposes the number of live sessions. Sessions held in
+// Postgres are counted with a query, so a scrape never loads their data.
+func (m *metrics) registerSessions(store scs.Store, db *pgxpool.Pool) {
+	var count func() (int, error)
+	switch store := store.(type) {
+	case *pgxstore.PostgresStore:
+		count = func() (int, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), sessionCountTimeout)
+			defer cancel()
+
+			var n int
+			err := db.QueryRow(ctx, "SELECT count(*) FROM sessions WHERE expiry > current_timestamp").Scan(&n)
+			return n, err
+		}
+	case scs.IterableStore:
+		count = func() (int, error) {
+			sessions, err := store.All()
+			return len(sessions), err
+		}
+	default:
+		return
+	}
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "snippetbox_sessions_active",
+		Help: "Sessions currently held in the session store.",
+	}, func() float64 {
+		n, err := count()
+		if err != nil {
+			return 0
+		}
+		return float64(n)
+	}))
+}
+
+type routeContextKey struct{}
+
+// instrumentedRouter records the pattern of the matched route so that request
+// metrics are labelled by "/snippet/view/:id" rather than by every distinct URL.
+type instrumentedRouter struct {
+	*httprouter.Router
+}
+
+func (router instrumentedRouter) Handler(method, path string, handler http.Handler) {
+	router.Router.Handler(method, path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeContextKey{}).(*string); ok {
+			*route = path
+		}
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+func (router instrumentedRouter) HandlerFunc(method, path string, handler http.HandlerFunc) {
+	router.Handler(method, path, handler)
+}
+
+func (app *application) instrumentRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		route := ""
+		ctx := context.WithValue(r.Context(), routeContextKey{}, &route)
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		if route == "" {
+			route = "unmatched"
+		}
+		method := metricMethod(r.Method)
+		status := strconv.Itoa(rw.status)
+		app.metrics.requests.WithLabelValues(route, method, status).Inc()
+		app.metrics.requestDuration.WithLabelValues(route, method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// metricMethod returns the method label for a request. The server accepts any
+// method token, so methods outside the standard set share one label rather
+// than each adding time series.
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "other"
+	}
+}
+
+type queryContextKey struct{}
+
+type queryTrace struct {
+	start time.Time
+	span  trace.Span
+}
+
+// queryTracer times every query run through the pool, attributes it to the
+// model method that issued it and records it as a child span of the request.
+type queryTracer struct {
+	metrics *metrics
+	tracer  trace.Tracer
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, span := t.tracer.Start(ctx, querySpanName(models.Method(ctx), data.SQL),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBStatementKey.String(data.SQL)),
+	)
+	return context.WithValue(ctx, queryContextKey{}, &queryTrace{start: time.Now(), span: span})
+}
+
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	q, ok := ctx.Value(queryContextKey{}).(*queryTrace)
+	if !ok {
+		return
+	}
+	method := models.Method(ctx)
+	if method == "" {
+		method = "other"
+	}
+	outcome := "ok"
+	if data.Err != nil {
+		outcome = "error"
+		q.span.RecordError(data.Err)
+		q.span.SetStatus(codes.Error, data.Err.Error())
+	}
+	q.span.End()
+	t.metrics.queryDuration.WithLabelValues(method, outcome).Observe(time.Since(q.start).Seconds())
+}
+
+var (
+	poolAcquiredDesc = prometheus.NewDesc("snippetbox_db_pool_acquired_connections",
+		"Connections currently in use.", nil, nil)
+	poolIdleDesc = prometheus.NewDesc("snippetbox_db_pool_idle_connections",
+		"Connections currently idle.", nil, nil)
+	poolTotalDesc = prometheus.NewDesc("snippetbox_db_pool_total_connections",
+		"Connections currently open, including those still being established.", nil, nil)
+	poolMaxDesc = prometheus.NewDesc("snippetbox_db_pool_max_connections",
+		"Maximum size of the pool.", nil, nil)
+	poolAcquiresDesc = prometheus.NewDesc("snippetbox_db_pool_acquires_total",
+		"Successful connection acquisitions.", nil, nil)
+	poolEmptyAcquiresDesc = prometheus.NewDesc("snippetbox_db_pool_empty_acquires_total",
+		"Acquisitions that had to wait because the pool was empty.", nil, nil)
+	poolCanceledAcquiresDesc = prometheus.NewDesc("snippetbox_db_pool_canceled_acquires_total",
+		"Acquisitions canceled by their context.", nil, nil)
+	poolAcquireDurationDesc = prometheus.NewDesc("snippetbox_db_pool_acquire_duration_seconds_total",
+		"Total time spent waiting to acquire a connection.", nil, nil)
+)
+
+type poolCollector struct {
+	pool *pgxpool.Pool
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolAcquiredDesc
+	ch <- poolIdleDesc
+	ch <- poolTotalDesc
+	ch <- poolMaxDesc
+	ch <- poolAcquiresDesc
+	ch <- poolEmptyAcquiresDesc
+	ch <- poolCanceledAcquiresDesc
+	ch <- poolAcquireDurationDesc
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(poolAcquiredDesc, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdleDesc, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotalDesc, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMaxDesc, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquiresDesc, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquiresDesc, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolCanceledAcquiresDesc, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireDurationDesc, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
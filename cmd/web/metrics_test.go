@@ -0,0 +1,39 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/purple-mountain/snippetbox/internal/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.get(t, "/snippet/view/1")
+	ts.get(t, "/snippet/view/2")
+	ts.get(t, "/no/such/page")
+	ts.do(t, "FROBNICATE", "/no/such/page", "", "")
+
+	statusCode, _, _ := ts.get(t, "/metrics")
+	assert.Equal(t, statusCode, http.StatusNotFound)
+
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	app.adminRoutes().ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	body, err := io.ReadAll(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.StringContains(t, string(body), `snippetbox_http_requests_total{method="GET",route="/snippet/view/:id",status="200"} 1`)
+	assert.StringContains(t, string(body), `snippetbox_http_requests_total{method="GET",route="/snippet/view/:id",status="404"} 1`)
+	assert.StringContains(t, string(body), `snippetbox_http_requests_total{method="GET",route="unmatched",status="404"} 2`)
+	assert.StringContains(t, string(body), `snippetbox_http_requests_total{method="other",route="unmatched",status="404"} 1`)
+	assert.StringContains(t, string(body), `snippetbox_http_request_duration_seconds_count{method="GET",route="/snippet/view/:id",status="200"} 1`)
+}
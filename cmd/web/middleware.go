@@ -2,10 +2,17 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
+	"regexp"
+	"time"
 
+	"github.com/justinas/alice"
 	"github.com/justinas/nosurf"
+	"github.com/purple-mountain/snippetbox/internal/models"
 )
 
 func (app *application) authenticate(next http.Handler) http.Handler {
@@ -15,19 +22,59 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			next.ServeHTTP(w, r)
 			return
 		}
-		exists, err := app.users.Exists(id)
-		if err != nil {
-			app.serverError(w, err)
+		epoch, err := app.users.SessionEpoch(r.Context(), id)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
 			return
 		}
-		if exists {
+		if err == nil && epoch == app.sessionManager.GetInt(r.Context(), "sessionEpoch") {
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
 			r = r.WithContext(ctx)
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// authenticateToken signs in requests that carry an API token in an
+// "Authorization: Bearer" header. Tokens with the read scope are limited to
+// safe methods. Only requests without a header go through the session chain:
+// token requests are not sent by browsers, so they skip the CSRF check, and
+// they get a fresh session that is never saved.
+func (app *application) authenticateToken(session alice.Chain) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		withSession := session.Then(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Authorization")
+			apiToken, err := app.bearerToken(r)
+			if err != nil {
+				if errors.Is(err, models.ErrInvalidToken) {
+					app.invalidToken(w)
+				} else {
+					app.serverError(w, r, err)
+				}
+				return
+			}
+			if apiToken == nil {
+				withSession.ServeHTTP(w, r)
+				return
+			}
+			if !tokenAllows(apiToken, r) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+				app.clientError(w, http.StatusForbidden)
+				return
+			}
+
+			ctx, err := app.sessionManager.Load(r.Context(), "")
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, withAPIToken(r.WithContext(ctx), apiToken))
+		})
+	}
+}
+
 func noSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
 	csrfHandler.SetBaseCookie(http.Cookie{
@@ -50,6 +97,22 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+func (app *application) requireVerified(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := app.users.GetUser(r.Context(), app.authenticatedUserID(r))
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if !user.Verified {
+			app.sessionManager.Put(r.Context(), "flash", "Please verify your email address before creating snippets.")
+			http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func secureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Security-Policy",
@@ -62,10 +125,69 @@ func secureHeaders(next http.Handler) http.Handler {
 	})
 }
 
+var requestIDRX = regexp.MustCompile(`^[\w.-]{1,128}$`)
+
+func requestIDs(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDRX.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (rw *responseRecorder) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
+}
+
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
-		next.ServeHTTP(w, r)
+		start := time.Now()
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+		app.requestLogger(r).Info("request completed",
+			"remote_addr", r.RemoteAddr,
+			"proto", r.Proto,
+			"status", rw.status,
+			"bytes", rw.bytes,
+			"duration", time.Since(start),
+		)
 	})
 }
 
@@ -73,8 +195,9 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
+				app.metrics.panics.Inc()
 				w.Header().Set("Connection", "close")
-				app.serverError(w, fmt.Errorf("%s", err))
+				app.serverError(w, r, fmt.Errorf("%s", err))
 			}
 		}()
 		next.ServeHTTP(w, r)
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/purple-mountain/snippetbox/internal/migrations"
+)
+
+const migrateUsage = "usage: web migrate up | down [steps] | status"
+
+func runMigrate(ctx context.Context, db migrations.DB, args []string, logger *slog.Logger) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrations.Up(ctx, db)
+		for _, m := range applied {
+			logger.Info("applied migration", "version", m.Version, "name", m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			logger.Info("the schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		reverted, err := migrations.Down(ctx, db, steps)
+		for _, m := range reverted {
+			logger.Info("reverted migration", "version", m.Version, "name", m.Name)
+		}
+		return err
+	case "status":
+		all, err := migrations.All()
+		if err != nil {
+			return err
+		}
+		applied, err := migrations.Applied(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, m := range all {
+			status := "pending"
+			if applied[m.Version] {
+				status = "applied"
+			}
+			logger.Info("migration", "version", m.Version, "name", m.Name, "status", status)
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
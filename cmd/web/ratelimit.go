@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// limiterIdleTime is how long a key must go unused before its limiter is
+// forgotten. It is long enough for any limiter in use to have refilled.
+const limiterIdleTime = time.Hour
+
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter keeps a token bucket per key, such as a client IP address or an
+// email address. It only lives in memory, so every instance limits on its own.
+type rateLimiter struct {
+	mu        sync.Mutex
+	limit     rate.Limit
+	burst     int
+	entries   map[string]*limiterEntry
+	lastPrune time.Time
+}
+
+func newRateLimiter(limit rate.Limit, burst int) *rateLimiter {
+	return &rateLimiter{
+		limit:     limit,
+		burst:     burst,
+		entries:   map[string]*limiterEntry{},
+		lastPrune: time.Now(),
+	}
+}
+
+func (l *rateLimiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastPrune) > time.Minute {
+		for k, e := range l.entries {
+			if now.Sub(e.lastSeen) > limiterIdleTime {
+				delete(l.entries, k)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	e, ok := l.entries[key]
+	if !ok {
+		e = &limiterEntry{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.entries[key] = e
+	}
+	e.lastSeen = now
+	return e.limiter.AllowN(now, 1)
+}
+
+// clientIP returns the address of the client, read from the header set by the
+// proxy in front of the application when one is configured.
+func (app *application) clientIP(r *http.Request) string {
+	if app.clientIPHeader != "" {
+		if ip := r.Header.Get(app.clientIPHeader); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (app *application) rateLimit(l *rateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !l.allow(app.clientIP(r)) {
+				w.Header().Set("Retry-After", "60")
+				app.clientError(w, http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
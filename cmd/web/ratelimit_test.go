@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/purple-mountain/snippetbox/internal/assert"
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(rate.Every(time.Hour), 2)
+
+	assert.Equal(t, l.allow("a"), true)
+	assert.Equal(t, l.allow("a"), true)
+	assert.Equal(t, l.allow("a"), false)
+	assert.Equal(t, l.allow("b"), true)
+}
+
+func TestClientIP(t *testing.T) {
+	app := newTestApplication(t)
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:4321"
+	r.Header.Set("Fly-Client-IP", "203.0.113.7")
+
+	assert.Equal(t, app.clientIP(r), "10.0.0.1")
+	app.clientIPHeader = "Fly-Client-IP"
+	assert.Equal(t, app.clientIP(r), "203.0.113.7")
+}
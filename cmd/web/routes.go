@@ -2,6 +2,7 @@ package main
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/alice"
@@ -9,9 +10,13 @@ import (
 )
 
 func (app *application) routes() http.Handler {
-	router := httprouter.New()
+	router := instrumentedRouter{httprouter.New()}
 
 	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			app.apiNotFound(w, r)
+			return
+		}
 		app.notFound(w)
 	})
 
@@ -19,24 +24,69 @@ func (app *application) routes() http.Handler {
 	router.Handler(http.MethodGet, "/static/*filepath", fileServer)
 
 	router.HandlerFunc(http.MethodGet, "/ping", ping)
+	router.HandlerFunc(http.MethodGet, "/healthz", app.healthz)
+	router.HandlerFunc(http.MethodGet, "/readyz", app.readyz)
 
 	dynamic := alice.New(app.sessionManager.LoadAndSave, noSurf, app.authenticate)
 	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))
 	router.Handler(http.MethodGet, "/about", dynamic.ThenFunc(app.about))
+	router.Handler(http.MethodGet, "/search", dynamic.ThenFunc(app.search))
 	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))
+	router.Handler(http.MethodGet, "/snippet/view/:id/history", dynamic.ThenFunc(app.snippetHistory))
+	router.Handler(http.MethodGet, "/snippet/view/:id/history/:version", dynamic.ThenFunc(app.snippetRevision))
+	router.Handler(http.MethodGet, "/snippet/view/:id/diff", dynamic.ThenFunc(app.snippetDiff))
+	router.Handler(http.MethodGet, "/snippet/raw/:id", dynamic.ThenFunc(app.snippetRaw))
+	router.Handler(http.MethodGet, "/snippet/download/:id", dynamic.ThenFunc(app.snippetDownload))
 	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
 	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.userSignupPost))
 	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
 	router.Handler(http.MethodPost, "/user/login", dynamic.ThenFunc(app.userLoginPost))
+	router.Handler(http.MethodGet, "/user/login/2fa", dynamic.ThenFunc(app.userLoginTwoFactor))
+	router.Handler(http.MethodPost, "/user/login/2fa", dynamic.ThenFunc(app.userLoginTwoFactorPost))
+	router.Handler(http.MethodGet, "/user/verify", dynamic.ThenFunc(app.userVerify))
+	router.Handler(http.MethodGet, "/user/password/forgot", dynamic.ThenFunc(app.passwordForgot))
+	router.Handler(http.MethodPost, "/user/password/forgot", dynamic.Append(app.rateLimit(app.resetLimiter)).ThenFunc(app.passwordForgotPost))
+	router.Handler(http.MethodGet, "/user/password/reset", dynamic.ThenFunc(app.passwordReset))
+	router.Handler(http.MethodPost, "/user/password/reset", dynamic.Append(app.rateLimit(app.resetLimiter)).ThenFunc(app.passwordResetPost))
 
 	protected := dynamic.Append(app.requireAuthentication)
 	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
-	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreate))
-	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	router.Handler(http.MethodPost, "/user/verify/resend", protected.ThenFunc(app.userVerifyResendPost))
 	router.Handler(http.MethodGet, "/account/view", protected.ThenFunc(app.accountView))
 	router.Handler(http.MethodGet, "/account/password/update", protected.ThenFunc(app.passwordUpdate))
 	router.Handler(http.MethodPost, "/account/password/update", protected.ThenFunc(app.passwordUpdatePost))
+	router.Handler(http.MethodGet, "/account/2fa/enable", protected.ThenFunc(app.twoFactorEnable))
+	router.Handler(http.MethodPost, "/account/2fa/enable", protected.ThenFunc(app.twoFactorEnablePost))
+	router.Handler(http.MethodGet, "/account/2fa/qr", protected.ThenFunc(app.twoFactorQR))
+	router.Handler(http.MethodGet, "/account/2fa/disable", protected.ThenFunc(app.twoFactorDisable))
+	router.Handler(http.MethodPost, "/account/2fa/disable", protected.ThenFunc(app.twoFactorDisablePost))
+	router.Handler(http.MethodGet, "/account/tokens", protected.ThenFunc(app.accountTokens))
+	router.Handler(http.MethodPost, "/account/tokens", protected.ThenFunc(app.accountTokenCreatePost))
+	router.Handler(http.MethodPost, "/account/tokens/delete/:id", protected.ThenFunc(app.accountTokenDeletePost))
 
-	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
+	scriptable := alice.New(app.authenticateToken(dynamic), app.requireAuthentication)
+	router.Handler(http.MethodGet, "/snippet/create", scriptable.Append(app.requireVerified).ThenFunc(app.snippetCreate))
+	router.Handler(http.MethodPost, "/snippet/create", scriptable.Append(app.requireVerified).ThenFunc(app.snippetCreatePost))
+	router.Handler(http.MethodGet, "/snippet/edit/:id", scriptable.ThenFunc(app.snippetEdit))
+	router.Handler(http.MethodPost, "/snippet/edit/:id", scriptable.ThenFunc(app.snippetEditPost))
+	router.Handler(http.MethodGet, "/snippet/delete/:id", scriptable.ThenFunc(app.snippetDelete))
+	router.Handler(http.MethodPost, "/snippet/delete/:id", scriptable.ThenFunc(app.snippetDeletePost))
+
+	api := alice.New(app.apiAuthenticate)
+	router.Handler(http.MethodGet, "/api/v1/snippets", api.ThenFunc(app.apiSnippetList))
+	router.Handler(http.MethodGet, "/api/v1/snippets/:id", api.ThenFunc(app.apiSnippetView))
+
+	apiProtected := api.Append(app.apiRequireAuthentication)
+	router.Handler(http.MethodPost, "/api/v1/snippets", apiProtected.Append(app.apiRequireVerified).ThenFunc(app.apiSnippetCreate))
+	router.Handler(http.MethodPatch, "/api/v1/snippets/:id", apiProtected.ThenFunc(app.apiSnippetUpdate))
+	router.Handler(http.MethodDelete, "/api/v1/snippets/:id", apiProtected.ThenFunc(app.apiSnippetDelete))
+
+	standard := alice.New(requestIDs, app.logRequest, app.instrumentRequest, app.traceRequest, app.recoverPanic, secureHeaders)
 	return standard.Then(router)
 }
+
+func (app *application) adminRoutes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", app.metrics.handler())
+	return mux
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func (app *application) serve(srv, admin *http.Server, drain, grace time.Duration) error {
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		app.shuttingDown.Store(true)
+		app.logger.Info("draining traffic", "signal", s.String(), "drain", drain.String())
+		time.Sleep(drain)
+
+		app.logger.Info("shutting down the server", "grace", grace.String())
+		ctx, cancel := context.WithTimeout(context.Background(), grace)
+		defer cancel()
+
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			shutdownError <- err
+			return
+		}
+		if admin != nil {
+			app.logger.Info("stopping the admin server")
+			err = admin.Shutdown(ctx)
+			if err != nil {
+				shutdownError <- err
+				return
+			}
+		}
+
+		app.logger.Info("waiting for background tasks to finish")
+		done := make(chan struct{})
+		go func() {
+			app.wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			shutdownError <- nil
+		case <-ctx.Done():
+			shutdownError <- fmt.Errorf("background tasks still running: %w", ctx.Err())
+		}
+	}()
+
+	if admin != nil {
+		go func() {
+			app.logger.Info("starting the admin server", "addr", admin.Addr)
+			err := admin.ListenAndServe()
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.logger.Error("admin server failed", "error", err.Error())
+			}
+		}()
+	}
+
+	app.logger.Info("starting the server", "addr", srv.Addr)
+	err := srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	err = <-shutdownError
+	if err != nil {
+		return err
+	}
+	app.logger.Info("stopped the server")
+	return nil
+}
@@ -4,21 +4,54 @@ import (
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/purple-mountain/snippetbox/internal/diff"
+	"github.com/purple-mountain/snippetbox/internal/highlight"
+	"github.com/purple-mountain/snippetbox/internal/markdown"
 	"github.com/purple-mountain/snippetbox/internal/models"
 	"github.com/purple-mountain/snippetbox/ui"
 )
 
 type templateData struct {
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
-	CurrentYear     int
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
-	User            *models.User
+	Snippet             *models.Snippet
+	Snippets            []*models.Snippet
+	Page                *models.SnippetPage
+	Revision            *models.Revision
+	Revisions           []*models.Revision
+	Diff                *revisionDiff
+	ShowSource          bool
+	SearchQuery         string
+	SearchResults       *models.SearchResults
+	CurrentYear         int
+	Form                any
+	Flash               string
+	IsAuthenticated     bool
+	AuthenticatedUserID int
+	CSRFToken           string
+	User                *models.User
+	TwoFactor           *twoFactorSetup
+	RecoveryCodes       []string
+	APITokens           []*models.APIToken
+	NewAPIToken         string
+}
+
+// twoFactorSetup holds the secret being enrolled. URI is built by the server,
+// so it is marked safe for html/template, which otherwise rejects the
+// otpauth: scheme in links.
+type twoFactorSetup struct {
+	Secret string
+	URI    template.URL
+}
+
+type revisionDiff struct {
+	From         *models.Revision
+	To           *models.Revision
+	Split        bool
+	TooDifferent bool
+	Hunks        []diff.Hunk
+	Rows         []diff.Row
 }
 
 func humanDate(t time.Time) string {
@@ -28,8 +61,43 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// highlightMatches is the "highlight" template function. The Go name differs
+// so that it does not clash with the highlight package.
+func highlightMatches(s string) template.HTML {
+	escaped := template.HTMLEscapeString(s)
+	escaped = strings.ReplaceAll(escaped, models.HighlightStart, "<mark>")
+	escaped = strings.ReplaceAll(escaped, models.HighlightStop, "</mark>")
+	return template.HTML(escaped)
+}
+
+func renderContent(content, language string) (template.HTML, error) {
+	if language == "markdown" {
+		html, err := markdown.Render(content)
+		if err != nil {
+			return "", err
+		}
+		return `<div class="markdown">` + html + `</div>`, nil
+	}
+	return highlight.HTML(content, language)
+}
+
+func languageLabel(name string) string {
+	l, ok := highlight.Lookup(name)
+	if !ok {
+		return name
+	}
+	return l.Label
+}
+
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":     humanDate,
+	"highlight":     highlightMatches,
+	"syntax":        highlight.HTML,
+	"renderContent": renderContent,
+	"languageLabel": languageLabel,
+	"languages": func() []highlight.Language {
+		return highlight.Languages
+	},
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
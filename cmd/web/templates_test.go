@@ -1,10 +1,12 @@
 package main
 
 import (
+	"html/template"
 	"testing"
 	"time"
 
 	"github.com/purple-mountain/snippetbox/internal/assert"
+	"github.com/purple-mountain/snippetbox/internal/models"
 )
 
 func TestHumanDate(t *testing.T) {
@@ -36,3 +38,32 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want template.HTML
+	}{
+		{
+			name: "Plain",
+			s:    "no matches here",
+			want: "no matches here",
+		},
+		{
+			name: "Match",
+			s:    "an old " + models.HighlightStart + "pond" + models.HighlightStop,
+			want: "an old <mark>pond</mark>",
+		},
+		{
+			name: "Escapes HTML",
+			s:    "<b>" + models.HighlightStart + "x" + models.HighlightStop + "</b>",
+			want: "&lt;b&gt;<mark>x</mark>&lt;/b&gt;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, highlightMatches(tt.s), tt.want)
+		})
+	}
+}
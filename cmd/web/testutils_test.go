@@ -4,18 +4,21 @@ import (
 	"bytes"
 	"html"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
 	"github.com/purple-mountain/snippetbox/internal/mocks"
+	"go.opentelemetry.io/otel/trace/noop"
+	"golang.org/x/time/rate"
 )
 
 var csrfTokenRX = regexp.MustCompile(`<input type="hidden" name="csrf_token" value="(.+)" />`)
@@ -41,13 +44,22 @@ func newTestApplication(t *testing.T) *application {
 	sessionManager.Cookie.Secure = true
 
 	return &application{
-		errorLog:       log.New(io.Discard, "", 0),
-		infoLog:        log.New(io.Discard, "", 0),
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
 		users:          &mocks.UserModel{},
+		tokens:         &mocks.TokenModel{},
+		apiTokens:      &mocks.APITokenModel{},
+		mailer:         &mocks.Mailer{},
+		baseURL:        "https://snippetbox.example.com",
 		snippets:       &mocks.SnippetModel{},
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		metrics:        newMetrics(),
+		tracer:         noop.NewTracerProvider().Tracer(tracerName),
+
+		resetLimiter:      newRateLimiter(rate.Every(time.Minute), 5),
+		resetEmailLimiter: newRateLimiter(rate.Every(15*time.Minute), 3),
+		twoFactorLimiter:  newRateLimiter(rate.Every(time.Minute), 5),
 	}
 }
 
@@ -103,3 +115,41 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 
 	return rs.StatusCode, rs.Header, string(body)
 }
+
+func (ts *testServer) do(t *testing.T, method, urlPath, token, body string) (int, http.Header, string) {
+	req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	b, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs.StatusCode, rs.Header, string(bytes.TrimSpace(b))
+}
+
+func (ts *testServer) login(t *testing.T, email, password string) {
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", password)
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	statusCode, _, _ := ts.postForm(t, "/user/login", form)
+	if statusCode != http.StatusSeeOther {
+		t.Fatalf("login failed with status %d", statusCode)
+	}
+}
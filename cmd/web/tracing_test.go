@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/purple-mountain/snippetbox/internal/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+func TestTraceRequest(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	app := newTestApplication(t)
+	app.tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer(tracerName)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/snippet/view/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans; want 2", len(spans))
+	}
+	render, request := spans[0], spans[1]
+
+	assert.Equal(t, request.Name(), "GET /snippet/view/:id")
+	assert.Equal(t, request.SpanContext().TraceID().String(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Equal(t, request.Parent().SpanID().String(), "00f067aa0ba902b7")
+	for _, attr := range request.Attributes() {
+		switch attr.Key {
+		case semconv.HTTPRouteKey:
+			assert.Equal(t, attr.Value.AsString(), "/snippet/view/:id")
+		case semconv.HTTPResponseStatusCodeKey:
+			assert.Equal(t, attr.Value.AsInt64(), int64(http.StatusOK))
+		}
+	}
+
+	assert.Equal(t, render.Name(), "render view.tmpl.html")
+	assert.Equal(t, render.Parent().SpanID(), request.SpanContext().SpanID())
+}
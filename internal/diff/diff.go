@@ -0,0 +1,248 @@
+// Package diff computes line-based differences between two texts using
+// Myers' O(ND) algorithm and groups them into unified hunks or side-by-side
+// rows for display.
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxEdits caps the length of the edit script Lines looks for. Backtracking
+// keeps a copy of the search state for every edit, so memory grows with the
+// square of the number of edits.
+const MaxEdits = 1000
+
+var ErrTooDifferent = errors.New("diff: texts differ by more than MaxEdits lines")
+
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+func (o Op) String() string {
+	switch o {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+// Line is a single line of the edit script. OldNumber and NewNumber are
+// 1-based positions in the old and new text, and are 0 when the line is
+// absent from that side.
+type Line struct {
+	Op        Op
+	Text      string
+	OldNumber int
+	NewNumber int
+}
+
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// Row pairs up lines for a side-by-side view. Either side is nil when the
+// line only exists in the other text.
+type Row struct {
+	Old *Line
+	New *Line
+}
+
+func Strings(a, b string) ([]Line, error) {
+	return Lines(splitLines(a), splitLines(b))
+}
+
+// Lines returns the shortest edit script that turns a into b, or
+// ErrTooDifferent if it is longer than MaxEdits.
+func Lines(a, b []string) ([]Line, error) {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+
+	// trace[d] holds the furthest reaching x for every diagonal k in [-d, d]
+	// as it was before step d, which is all backtracking needs.
+	var trace [][]int
+	for d := 0; d <= min(max, MaxEdits); d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b), nil
+			}
+		}
+	}
+	return nil, ErrTooDifferent
+}
+
+func backtrack(trace [][]int, a, b []string) []Line {
+	x, y := len(a), len(b)
+	var reversed []Line
+	equal := func() {
+		reversed = append(reversed, Line{Op: Equal, Text: a[x-1], OldNumber: x, NewNumber: y})
+		x--
+		y--
+	}
+
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			equal()
+		}
+		if x == prevX {
+			reversed = append(reversed, Line{Op: Insert, Text: b[y-1], NewNumber: y})
+			y--
+		} else {
+			reversed = append(reversed, Line{Op: Delete, Text: a[x-1], OldNumber: x})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		equal()
+	}
+
+	lines := make([]Line, len(reversed))
+	for i, line := range reversed {
+		lines[len(reversed)-1-i] = line
+	}
+	return lines
+}
+
+// Unified groups the edit script into hunks, keeping up to context
+// unchanged lines around every change.
+func Unified(lines []Line, context int) []Hunk {
+	var hunks []Hunk
+	oldBefore, newBefore := make([]int, len(lines)+1), make([]int, len(lines)+1)
+	for i, line := range lines {
+		oldBefore[i+1], newBefore[i+1] = oldBefore[i], newBefore[i]
+		if line.Op != Insert {
+			oldBefore[i+1]++
+		}
+		if line.Op != Delete {
+			newBefore[i+1]++
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			i++
+			continue
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(lines) {
+			if lines[end].Op != Equal {
+				end++
+				continue
+			}
+			run := end
+			for run < len(lines) && lines[run].Op == Equal {
+				run++
+			}
+			if run == len(lines) || run-end > 2*context {
+				end += min(context, run-end)
+				break
+			}
+			end = run
+		}
+
+		h := Hunk{
+			OldLines: oldBefore[end] - oldBefore[start],
+			NewLines: newBefore[end] - newBefore[start],
+			Lines:    lines[start:end],
+		}
+		h.OldStart = oldBefore[start]
+		if h.OldLines > 0 {
+			h.OldStart++
+		}
+		h.NewStart = newBefore[start]
+		if h.NewLines > 0 {
+			h.NewStart++
+		}
+		hunks = append(hunks, h)
+		i = end
+	}
+	return hunks
+}
+
+// SideBySide lays the edit script out in two columns, pairing each run of
+// deleted lines with the run of inserted lines that replaces it.
+func SideBySide(lines []Line) []Row {
+	var rows []Row
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			rows = append(rows, Row{Old: &lines[i], New: &lines[i]})
+			i++
+			continue
+		}
+		var deleted, inserted []*Line
+		for ; i < len(lines) && lines[i].Op != Equal; i++ {
+			if lines[i].Op == Delete {
+				deleted = append(deleted, &lines[i])
+			} else {
+				inserted = append(inserted, &lines[i])
+			}
+		}
+		for j := 0; j < len(deleted) || j < len(inserted); j++ {
+			var row Row
+			if j < len(deleted) {
+				row.Old = deleted[j]
+			}
+			if j < len(inserted) {
+				row.New = inserted[j]
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,170 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/purple-mountain/snippetbox/internal/assert"
+)
+
+func render(lines []Line) string {
+	var b strings.Builder
+	for _, line := range lines {
+		switch line.Op {
+		case Insert:
+			b.WriteString("+")
+		case Delete:
+			b.WriteString("-")
+		default:
+			b.WriteString(" ")
+		}
+		b.WriteString(line.Text)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func mustStrings(t *testing.T, a, b string) []Line {
+	t.Helper()
+	lines, err := Strings(a, b)
+	assert.NilError(t, err)
+	return lines
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "Both Empty",
+			a:    "",
+			b:    "",
+			want: "",
+		},
+		{
+			name: "Identical",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: " a\n b\n",
+		},
+		{
+			name: "Insert Only",
+			a:    "",
+			b:    "a\nb",
+			want: "+a\n+b\n",
+		},
+		{
+			name: "Delete Only",
+			a:    "a\nb",
+			b:    "",
+			want: "-a\n-b\n",
+		},
+		{
+			name: "Replace Middle",
+			a:    "a\nb\nc",
+			b:    "a\nx\nc",
+			want: " a\n-b\n+x\n c\n",
+		},
+		{
+			name: "Myers Example",
+			a:    "A\nB\nC\nA\nB\nB\nA",
+			b:    "C\nB\nA\nB\nA\nC",
+			want: "-A\n-B\n C\n+B\n A\n B\n-B\n A\n+C\n",
+		},
+		{
+			name: "CRLF",
+			a:    "a\r\nb\r\n",
+			b:    "a\nb\n",
+			want: " a\n b\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, render(mustStrings(t, tt.a, tt.b)), tt.want)
+		})
+	}
+}
+
+func TestLineNumbers(t *testing.T) {
+	lines := mustStrings(t, "a\nb\nc", "a\nx\nc")
+	want := []Line{
+		{Op: Equal, Text: "a", OldNumber: 1, NewNumber: 1},
+		{Op: Delete, Text: "b", OldNumber: 2},
+		{Op: Insert, Text: "x", NewNumber: 2},
+		{Op: Equal, Text: "c", OldNumber: 3, NewNumber: 3},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines; want %d", len(lines), len(want))
+	}
+	for i := range want {
+		assert.Equal(t, lines[i], want[i])
+	}
+}
+
+func TestTooDifferent(t *testing.T) {
+	a := make([]string, MaxEdits/2+1)
+	b := make([]string, len(a))
+	for i := range a {
+		a[i] = fmt.Sprintf("a%d", i)
+		b[i] = fmt.Sprintf("b%d", i)
+	}
+	_, err := Lines(a, b)
+	assert.Equal(t, err, ErrTooDifferent)
+
+	_, err = Lines(a[:len(a)-1], b[:len(b)-1])
+	assert.NilError(t, err)
+}
+
+func TestUnified(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+
+	tests := []struct {
+		name    string
+		context int
+		want    []string
+	}{
+		{
+			name:    "Separate Hunks",
+			context: 2,
+			want:    []string{"@@ -1,5 +1,5 @@", "@@ -11,2 +11,3 @@"},
+		},
+		{
+			name:    "Merged Hunks",
+			context: 5,
+			want:    []string{"@@ -1,12 +1,13 @@"},
+		},
+		{
+			name:    "No Context",
+			context: 0,
+			want:    []string{"@@ -3,1 +3,1 @@", "@@ -12,0 +13,1 @@"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hunks := Unified(mustStrings(t, a, b), tt.context)
+			if len(hunks) != len(tt.want) {
+				t.Fatalf("got %d hunks; want %d", len(hunks), len(tt.want))
+			}
+			for i := range hunks {
+				assert.Equal(t, hunks[i].Header(), tt.want[i])
+			}
+		})
+	}
+}
+
+func TestSideBySide(t *testing.T) {
+	rows := SideBySide(mustStrings(t, "a\nb\nc\nd", "a\nx\nd"))
+
+	assert.Equal(t, len(rows), 4)
+	assert.Equal(t, rows[0].Old.Text, "a")
+	assert.Equal(t, rows[1].Old.Text, "b")
+	assert.Equal(t, rows[1].New.Text, "x")
+	assert.Equal(t, rows[2].Old.Text, "c")
+	assert.Equal(t, rows[2].New == nil, true)
+	assert.Equal(t, rows[3].New.Text, "d")
+}
@@ -0,0 +1,177 @@
+package highlight
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"strings"
+)
+
+type Detection struct {
+	Language   string
+	Confidence float64
+}
+
+var interpreters = map[string]string{
+	"bash":    "bash",
+	"sh":      "bash",
+	"zsh":     "bash",
+	"ksh":     "bash",
+	"node":    "javascript",
+	"php":     "php",
+	"python":  "python",
+	"python2": "python",
+	"python3": "python",
+	"ruby":    "ruby",
+}
+
+var extensions = map[string]string{
+	".bash":         "bash",
+	".c":            "c",
+	".cc":           "cpp",
+	".cpp":          "cpp",
+	".css":          "css",
+	".diff":         "diff",
+	".go":           "go",
+	".h":            "c",
+	".hpp":          "cpp",
+	".htm":          "html",
+	".html":         "html",
+	".java":         "java",
+	".js":           "javascript",
+	".json":         "json",
+	".md":           "markdown",
+	".mjs":          "javascript",
+	".patch":        "diff",
+	".php":          "php",
+	".py":           "python",
+	".rb":           "ruby",
+	".rs":           "rust",
+	".sh":           "bash",
+	".sql":          "sql",
+	".toml":         "toml",
+	".ts":           "typescript",
+	".xml":          "xml",
+	".yaml":         "yaml",
+	".yml":          "yaml",
+	"dockerfile":    "dockerfile",
+	"containerfile": "dockerfile",
+}
+
+type rule struct {
+	rx     *regexp.Regexp
+	weight int
+}
+
+func rules(weight int, patterns ...string) []rule {
+	rs := make([]rule, len(patterns))
+	for i, p := range patterns {
+		rs[i] = rule{rx: regexp.MustCompile(p), weight: weight}
+	}
+	return rs
+}
+
+var keywords = map[string][]rule{
+	"go": append(rules(3, `(?m)^package \w+$`, `(?m)^func (\(\w+ \*?\w+\) )?\w+\(`, `:= `, `\bfmt\.\w+\(`),
+		rules(1, `(?m)^import \($`, `\berr != nil\b`, `\bchan\b`, `\bgo func\b`)...),
+	"python": append(rules(3, `(?m)^def \w+\(.*\):$`, `(?m)^(from [\w.]+ )?import \w+`, `if __name__ == .__main__.:`),
+		rules(1, `(?m)^class \w+(\(.*\))?:$`, `\bself\.`, `\bprint\(`, `\belif\b`)...),
+	"javascript": append(rules(3, `\bconsole\.log\(`, `\bfunction\s*\w*\(`, `=> \{`, `\brequire\(['"]`),
+		rules(1, `\b(const|let|var) \w+ =`, `\bdocument\.\w+`, `===`)...),
+	"typescript": rules(3, `\binterface \w+ \{`, `: (string|number|boolean)\b`, `\bimport .* from ['"]`),
+	"sql": append(rules(3, `(?i)\bselect\b[\s\S]+\bfrom\b`, `(?i)\binsert into\b`, `(?i)\bcreate (table|index)\b`),
+		rules(1, `(?i)\b(where|join|group by|order by|values)\b`, `(?i)\bupdate \w+ set\b`)...),
+	"yaml": append(rules(3, `(?m)^---$`, `(?m)^[\w-]+:\s*$`),
+		rules(1, `(?m)^\s*- [\w"']`, `(?m)^\s*[\w-]+: \S`)...),
+	"bash": append(rules(3, `(?m)^\s*(export|echo|sudo|apt-get|cd) `, `\$\{?\w+\}?`, `(?m)^\s*fi$`),
+		rules(1, `(?m)^\s*if \[`, `\s\|\s`, `&&`)...),
+	"rust":       rules(3, `\bfn \w+\(`, `\blet mut\b`, `\bimpl\b`, `\bprintln!\(`),
+	"java":       rules(3, `\bpublic (static )?(class|void)\b`, `\bSystem\.out\.print`, `\bprivate final\b`),
+	"c":          rules(3, `(?m)^#include <\w+\.h>`, `\bint main\(`, `\bprintf\(`),
+	"cpp":        rules(3, `(?m)^#include <\w+>$`, `\bstd::`, `\bcout <<`),
+	"ruby":       rules(3, `(?m)^\s*def \w+[^:]*$`, `(?m)^\s*end$`, `\bputs\b`, `\brequire '`),
+	"php":        rules(3, `<\?php`, `\$\w+->`, `\becho \$`),
+	"html":       rules(3, `(?i)<!DOCTYPE html>`, `(?i)<(html|head|body|div|span|p)\b[^>]*>`),
+	"css":        rules(3, `(?m)^[.#]?[\w-]+(\s*[.#:>]?[\w-]*)*\s*\{$`, `(?m)^\s*[\w-]+:\s*[^;]+;$`),
+	"xml":        rules(3, `^<\?xml `, `</\w+:\w+>`),
+	"dockerfile": rules(3, `(?m)^FROM \S+`, `(?m)^(RUN|CMD|ENTRYPOINT|COPY|WORKDIR) `),
+	"markdown": append(rules(3, "(?m)^```", `(?m)^#{1,6} \S`),
+		rules(1, `\[[^\]]+\]\([^)]+\)`, `(?m)^\s*[*-] \S`)...),
+	"diff": rules(3, `(?m)^@@ -\d+(,\d+)? \+\d+(,\d+)? @@`, `(?m)^(---|\+\+\+) \S`),
+	"toml": rules(3, `(?m)^\[[\w.]+\]$`, `(?m)^\w+ = ("|\d|true|false|\[)`),
+}
+
+// Detect guesses the language of a snippet from a shebang line, a file name
+// in the title and finally keyword heuristics. The confidence is between 0
+// and 1; a Detection with an empty Language means no useful guess was found.
+func Detect(title, content string) Detection {
+	if l, ok := detectShebang(content); ok {
+		return Detection{Language: l, Confidence: 0.95}
+	}
+	if l, ok := detectFilename(title); ok {
+		return Detection{Language: l, Confidence: 0.9}
+	}
+
+	trimmed := strings.TrimSpace(content)
+	if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+		return Detection{Language: "json", Confidence: 0.9}
+	}
+
+	best, bestScore, total := "", 0, 0
+	for language, rs := range keywords {
+		score := 0
+		for _, r := range rs {
+			if r.rx.MatchString(content) {
+				score += r.weight
+			}
+		}
+		total += score
+		if score > bestScore || (score == bestScore && score > 0 && language < best) {
+			best, bestScore = language, score
+		}
+	}
+	if bestScore < 3 {
+		return Detection{}
+	}
+
+	confidence := float64(bestScore) / float64(total)
+	if bestScore < 6 {
+		confidence *= 0.75
+	}
+	return Detection{Language: best, Confidence: min(confidence, 0.85)}
+}
+
+func detectShebang(content string) (string, bool) {
+	if !strings.HasPrefix(content, "#!") {
+		return "", false
+	}
+	line, _, _ := strings.Cut(content, "\n")
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return "", false
+	}
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" {
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") {
+				interpreter = f
+				break
+			}
+		}
+	}
+	l, ok := interpreters[interpreter]
+	return l, ok
+}
+
+func detectFilename(title string) (string, bool) {
+	for _, word := range strings.Fields(strings.ToLower(title)) {
+		word = strings.Trim(word, `"'()[],:;`)
+		if l, ok := extensions[word]; ok {
+			return l, true
+		}
+		if l, ok := extensions[path.Ext(word)]; ok {
+			return l, true
+		}
+	}
+	return "", false
+}
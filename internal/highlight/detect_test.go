@@ -0,0 +1,94 @@
+package highlight
+
+import (
+	"testing"
+
+	"github.com/purple-mountain/snippetbox/internal/assert"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		title   string
+		content string
+		want    string
+	}{
+		{
+			name:    "Shebang",
+			title:   "Deploy",
+			content: "#!/bin/bash\nset -e\n",
+			want:    "bash",
+		},
+		{
+			name:    "Env Shebang",
+			title:   "Script",
+			content: "#!/usr/bin/env -S python3 -u\nprint('hi')\n",
+			want:    "python",
+		},
+		{
+			name:    "Filename",
+			title:   "docker-compose.yml for the db",
+			content: "services:\n  postgres:\n    image: postgres\n",
+			want:    "yaml",
+		},
+		{
+			name:    "Dockerfile",
+			title:   "Our Dockerfile",
+			content: "FROM golang:1.21\n",
+			want:    "dockerfile",
+		},
+		{
+			name:    "JSON",
+			title:   "Response",
+			content: `{"id": 1, "title": "An old silent pond"}`,
+			want:    "json",
+		},
+		{
+			name:    "Go",
+			title:   "Hello",
+			content: "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tmsg := \"hi\"\n\tfmt.Println(msg)\n}\n",
+			want:    "go",
+		},
+		{
+			name:    "SQL",
+			title:   "Latest snippets",
+			content: "SELECT id, title FROM snippets\nWHERE expires > now()\nORDER BY id DESC;\n",
+			want:    "sql",
+		},
+		{
+			name:    "YAML",
+			title:   "CI",
+			content: "jobs:\n  build:\n    runs-on: ubuntu-latest\n    steps:\n      - uses: actions/checkout@v4\n",
+			want:    "yaml",
+		},
+		{
+			name:    "Python",
+			title:   "Fib",
+			content: "def fib(n):\n    if n < 2:\n        return n\n    return fib(n - 1) + fib(n - 2)\n\nprint(fib(10))\n",
+			want:    "python",
+		},
+		{
+			name:    "Shell",
+			title:   "Setup",
+			content: "export PATH=$HOME/bin:$PATH\ncd /tmp && echo done\n",
+			want:    "bash",
+		},
+		{
+			name:    "Plain Text",
+			title:   "Haiku",
+			content: "An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.",
+			want:    "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Detect(tt.title, tt.content)
+			assert.Equal(t, d.Language, tt.want)
+			if tt.want == "" {
+				assert.Equal(t, d.Confidence, 0.0)
+			} else {
+				assert.Equal(t, d.Confidence > 0 && d.Confidence <= 1, true)
+			}
+		})
+	}
+}
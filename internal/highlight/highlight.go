@@ -0,0 +1,106 @@
+// Package highlight renders snippet content as syntax highlighted HTML.
+//
+// The output only uses CSS classes, never inline styles, so that it works
+// under the Content-Security-Policy set by the web server. The matching
+// stylesheet lives in ui/static/css/chroma.css.
+package highlight
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+type Language struct {
+	Name      string
+	Label     string
+	Extension string
+	lexer     string
+}
+
+var Languages = []Language{
+	{Name: "text", Label: "Plain text", Extension: ".txt", lexer: "plaintext"},
+	{Name: "bash", Label: "Shell", Extension: ".sh", lexer: "bash"},
+	{Name: "c", Label: "C", Extension: ".c", lexer: "c"},
+	{Name: "cpp", Label: "C++", Extension: ".cpp", lexer: "c++"},
+	{Name: "css", Label: "CSS", Extension: ".css", lexer: "css"},
+	{Name: "diff", Label: "Diff", Extension: ".diff", lexer: "diff"},
+	{Name: "dockerfile", Label: "Dockerfile", Extension: ".dockerfile", lexer: "docker"},
+	{Name: "go", Label: "Go", Extension: ".go", lexer: "go"},
+	{Name: "html", Label: "HTML", Extension: ".html", lexer: "html"},
+	{Name: "java", Label: "Java", Extension: ".java", lexer: "java"},
+	{Name: "javascript", Label: "JavaScript", Extension: ".js", lexer: "javascript"},
+	{Name: "json", Label: "JSON", Extension: ".json", lexer: "json"},
+	{Name: "markdown", Label: "Markdown", Extension: ".md", lexer: "markdown"},
+	{Name: "php", Label: "PHP", Extension: ".php", lexer: "php"},
+	{Name: "python", Label: "Python", Extension: ".py", lexer: "python"},
+	{Name: "ruby", Label: "Ruby", Extension: ".rb", lexer: "ruby"},
+	{Name: "rust", Label: "Rust", Extension: ".rs", lexer: "rust"},
+	{Name: "sql", Label: "SQL", Extension: ".sql", lexer: "sql"},
+	{Name: "toml", Label: "TOML", Extension: ".toml", lexer: "toml"},
+	{Name: "typescript", Label: "TypeScript", Extension: ".ts", lexer: "typescript"},
+	{Name: "xml", Label: "XML", Extension: ".xml", lexer: "xml"},
+	{Name: "yaml", Label: "YAML", Extension: ".yaml", lexer: "yaml"},
+}
+
+var formatter = html.New(html.WithClasses(true))
+
+func Names() []string {
+	names := make([]string, len(Languages))
+	for i, l := range Languages {
+		names[i] = l.Name
+	}
+	return names
+}
+
+func Lookup(name string) (Language, bool) {
+	for _, l := range Languages {
+		if l.Name == name {
+			return l, true
+		}
+	}
+	return Language{}, false
+}
+
+// Resolve finds a language by its name or by a common alias such as a file
+// extension ("yml") or an interpreter ("python3").
+func Resolve(name string) (Language, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if l, ok := Lookup(name); ok && name != "" {
+		return l, true
+	}
+	if alias, ok := extensions["."+name]; ok {
+		return Lookup(alias)
+	}
+	if alias, ok := interpreters[name]; ok {
+		return Lookup(alias)
+	}
+	return Language{}, false
+}
+
+func HTML(content, language string) (template.HTML, error) {
+	l, ok := Lookup(language)
+	if !ok {
+		l = Languages[0]
+	}
+	lexer := lexers.Get(l.lexer)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, content)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = formatter.Format(&buf, styles.Get("github"), iterator)
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
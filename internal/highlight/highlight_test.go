@@ -0,0 +1,82 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/purple-mountain/snippetbox/internal/assert"
+)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		language string
+		want     string
+	}{
+		{
+			name:     "Go",
+			content:  "package main",
+			language: "go",
+			want:     `<span class="kn">package</span>`,
+		},
+		{
+			name:     "Plain Text",
+			content:  "An old silent pond...",
+			language: "",
+			want:     "An old silent pond...",
+		},
+		{
+			name:     "Unknown Language",
+			content:  "An old silent pond...",
+			language: "cobol",
+			want:     "An old silent pond...",
+		},
+		{
+			name:     "Escapes HTML",
+			content:  `<script>alert("hi")</script>`,
+			language: "",
+			want:     "&lt;script&gt;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := HTML(tt.content, tt.language)
+			assert.NilError(t, err)
+			assert.StringContains(t, string(html), tt.want)
+			assert.Equal(t, strings.Contains(string(html), "style="), false)
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	l, ok := Lookup("go")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, l.Label, "Go")
+
+	_, ok = Lookup("cobol")
+	assert.Equal(t, ok, false)
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{name: "go", want: "go", wantOK: true},
+		{name: "YAML", want: "yaml", wantOK: true},
+		{name: "yml", want: "yaml", wantOK: true},
+		{name: "sh", want: "bash", wantOK: true},
+		{name: "python3", want: "python", wantOK: true},
+		{name: "cobol", want: "", wantOK: false},
+		{name: "", want: "", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, ok := Resolve(tt.name)
+			assert.Equal(t, ok, tt.wantOK)
+			assert.Equal(t, l.Name, tt.want)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Package mailer delivers plain-text email, either through an SMTP server or,
+// for development, by writing it to a log.
+package mailer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// SMTP sends mail through the server at Addr, upgrading the connection with
+// STARTTLS when the server offers it. Username may be empty for servers that
+// do not require authentication.
+type SMTP struct {
+	Addr     string
+	Username string
+	Password string
+	Sender   string
+	Timeout  time.Duration
+}
+
+func (m *SMTP) Send(msg Message) error {
+	from, err := mail.ParseAddress(m.Sender)
+	if err != nil {
+		return fmt.Errorf("mailer: sender: %w", err)
+	}
+	host, _, err := net.SplitHostPort(m.Addr)
+	if err != nil {
+		return fmt.Errorf("mailer: %w", err)
+	}
+
+	timeout := m.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	conn, err := net.DialTimeout("tcp", m.Addr, timeout)
+	if err != nil {
+		return fmt.Errorf("mailer: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("mailer: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: host})
+		if err != nil {
+			return fmt.Errorf("mailer: %w", err)
+		}
+	}
+	if m.Username != "" {
+		err = c.Auth(smtp.PlainAuth("", m.Username, m.Password, host))
+		if err != nil {
+			return fmt.Errorf("mailer: %w", err)
+		}
+	}
+	err = c.Mail(from.Address)
+	if err != nil {
+		return fmt.Errorf("mailer: %w", err)
+	}
+	err = c.Rcpt(msg.To)
+	if err != nil {
+		return fmt.Errorf("mailer: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("mailer: %w", err)
+	}
+	_, err = w.Write(format(m.Sender, msg, time.Now()))
+	if err != nil {
+		return fmt.Errorf("mailer: %w", err)
+	}
+	err = w.Close()
+	if err != nil {
+		return fmt.Errorf("mailer: %w", err)
+	}
+	return c.Quit()
+}
+
+// format builds the RFC 5322 message for msg.
+func format(sender string, msg Message, date time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", sender)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	body := strings.ReplaceAll(msg.Body, "\r\n", "\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// Log records every message in Logger instead of sending it. The body is left
+// out, since the links in it work as credentials.
+type Log struct {
+	Logger *slog.Logger
+}
+
+func (m *Log) Send(msg Message) error {
+	m.Logger.Info("email", "to", msg.To, "subject", msg.Subject)
+	return nil
+}
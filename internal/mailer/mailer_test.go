@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/purple-mountain/snippetbox/internal/assert"
+)
+
+func TestFormat(t *testing.T) {
+	msg := Message{
+		To:      "alice@example.com",
+		Subject: "Vérifiez votre adresse",
+		Body:    "Hello\nWorld\n",
+	}
+	date := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	got := string(format("Snippetbox <no-reply@example.com>", msg, date))
+	assert.StringContains(t, got, "From: Snippetbox <no-reply@example.com>\r\n")
+	assert.StringContains(t, got, "To: alice@example.com\r\n")
+	assert.StringContains(t, got, "Subject: =?utf-8?q?V=C3=A9rifiez_votre_adresse?=\r\n")
+	assert.StringContains(t, got, "Date: Wed, 01 May 2024 10:00:00 +0000\r\n")
+	assert.StringContains(t, got, "\r\n\r\nHello\r\nWorld\r\n")
+}
+
+func TestLog(t *testing.T) {
+	var b bytes.Buffer
+	m := &Log{Logger: slog.New(slog.NewTextHandler(&b, nil))}
+
+	err := m.Send(Message{To: "alice@example.com", Subject: "Hi", Body: "Hello"})
+	assert.NilError(t, err)
+	assert.StringContains(t, b.String(), "to=alice@example.com")
+	assert.Equal(t, strings.Contains(b.String(), "Hello"), false)
+}
@@ -0,0 +1,102 @@
+// Package markdown renders Markdown snippets to sanitized HTML.
+//
+// Raw HTML in the source is never passed through and the rendered output is
+// run through an allow-list sanitizer, so the result is safe to embed in a
+// page even without relying on the Content-Security-Policy.
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/purple-mountain/snippetbox/internal/highlight"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+var md = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	goldmark.WithRendererOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&nodeRenderer{}, 100)),
+	),
+)
+
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[\w -]+$`)).OnElements("pre", "code", "span", "a")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}
+
+func Render(source string) (template.HTML, error) {
+	var buf bytes.Buffer
+	err := md.Convert([]byte(source), &buf)
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(policy.SanitizeBytes(buf.Bytes())), nil
+}
+
+type nodeRenderer struct{}
+
+func (r *nodeRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindHeading, r.renderHeading)
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+}
+
+func (r *nodeRenderer) renderHeading(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.Heading)
+	var id []byte
+	if v, ok := n.AttributeString("id"); ok {
+		id, _ = v.([]byte)
+	}
+	if entering {
+		fmt.Fprintf(w, "<h%d", n.Level)
+		if id != nil {
+			fmt.Fprintf(w, ` id="%s"`, util.EscapeHTML(id))
+		}
+		w.WriteByte('>')
+		return ast.WalkContinue, nil
+	}
+	if id != nil {
+		fmt.Fprintf(w, ` <a class="anchor" href="#%s">#</a>`, util.EscapeHTML(id))
+	}
+	fmt.Fprintf(w, "</h%d>\n", n.Level)
+	return ast.WalkContinue, nil
+}
+
+func (r *nodeRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+	var code bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		code.Write(line.Value(source))
+	}
+
+	language := ""
+	if l, ok := highlight.Resolve(string(n.Language(source))); ok {
+		language = l.Name
+	}
+	html, err := highlight.HTML(code.String(), language)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	w.WriteString(string(html))
+	w.WriteByte('\n')
+	return ast.WalkSkipChildren, nil
+}
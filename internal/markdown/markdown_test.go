@@ -0,0 +1,72 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/purple-mountain/snippetbox/internal/assert"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    string
+		wantNot string
+	}{
+		{
+			name:   "Heading Anchor",
+			source: "# Restart the server",
+			want:   `<h1 id="restart-the-server">Restart the server <a class="anchor" href="#restart-the-server" rel="nofollow">#</a></h1>`,
+		},
+		{
+			name:   "Fenced Code",
+			source: "```go\npackage main\n```",
+			want:   `<pre class="chroma"><code><span class="line"><span class="cl"><span class="kn">package</span>`,
+		},
+		{
+			name:   "Fenced Code Alias",
+			source: "```yml\nkey: value\n```",
+			want:   `<span class="nt">key</span>`,
+		},
+		{
+			name:   "Table",
+			source: "| a | b |\n|---|---|\n| 1 | 2 |",
+			want:   "<td>1</td>",
+		},
+		{
+			name:    "Script Tag",
+			source:  "hello <script>alert(1)</script>",
+			want:    "hello",
+			wantNot: "<script",
+		},
+		{
+			name:    "Style Tag",
+			source:  "<style>body { display: none }</style>\n\ntext",
+			want:    "text",
+			wantNot: "<style",
+		},
+		{
+			name:    "Inline Style Attribute",
+			source:  `<p style="color: red" onclick="alert(1)">hi</p>`,
+			wantNot: "style=",
+		},
+		{
+			name:    "Javascript Link",
+			source:  "[click](javascript:alert(1))",
+			wantNot: "javascript:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := Render(tt.source)
+			assert.NilError(t, err)
+			if tt.want != "" {
+				assert.StringContains(t, string(html), tt.want)
+			}
+			if tt.wantNot != "" && strings.Contains(string(html), tt.wantNot) {
+				t.Errorf("got %q; expected not to contain %q", html, tt.wantNot)
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+// Package migrations applies the versioned SQL files embedded from the sql
+// directory. File names have the form 0001_name.up.sql and 0001_name.down.sql
+// and the applied versions are recorded in the schema_migrations table.
+//
+// The first migration is the schema databases were set up with by hand before
+// migrations existed. Such databases are adopted by recording it as applied.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+//go:embed "sql"
+
+var files embed.FS
+
+// lockID is an arbitrary key for the advisory lock that stops two instances
+// starting at the same time from applying the same migration twice.
+const lockID = 7426083
+
+var fileRX = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type DB interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// All returns the embedded migrations ordered by version.
+func All() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		matches := fileRX.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("migrations: unexpected file %s", entry.Name())
+		}
+		version, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return nil, err
+		}
+		script, err := fs.ReadFile(files, "sql/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = m
+		} else if m.Name != matches[2] {
+			return nil, fmt.Errorf("migrations: version %d has two names: %s and %s", version, m.Name, matches[2])
+		}
+		if matches[3] == "up" {
+			m.Up = string(script)
+		} else {
+			m.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migrations: version %d needs both an up and a down script", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Applied returns the versions recorded in schema_migrations. It only reads
+// from the database, so a missing table simply means nothing is applied.
+func Applied(ctx context.Context, db DB) (map[int]bool, error) {
+	var exists bool
+	err := db.QueryRow(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return map[int]bool{}, nil
+	}
+
+	rows, err := db.Query(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var version int
+		err := rows.Scan(&version)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+func createTable(ctx context.Context, db DB) error {
+	stmt := `
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+      version INTEGER PRIMARY KEY,
+      name VARCHAR(255) NOT NULL,
+      applied TIMESTAMP WITH TIME ZONE NOT NULL
+    )
+  `
+	_, err := db.Exec(ctx, stmt)
+	return err
+}
+
+// Pending returns the migrations that have not been applied yet.
+func Pending(ctx context.Context, db DB) ([]Migration, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := Applied(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []Migration{}
+	for _, m := range all {
+		if !applied[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// adopt records the initial schema as applied if the database was set up by
+// hand, so that the first migration does not create tables that exist.
+func adopt(ctx context.Context, db DB) error {
+	all, err := All()
+	if err != nil {
+		return err
+	}
+	stmt := `
+    INSERT INTO schema_migrations (version, name, applied)
+    SELECT $1, $2, CURRENT_TIMESTAMP AT TIME ZONE 'UTC'
+    WHERE to_regclass('snippets') IS NOT NULL AND NOT EXISTS (SELECT true FROM schema_migrations)
+    ON CONFLICT DO NOTHING
+  `
+	_, err = db.Exec(ctx, stmt, all[0].Version, all[0].Name)
+	return err
+}
+
+// Up applies every pending migration in order, each in its own transaction,
+// and returns the ones it applied.
+func Up(ctx context.Context, db DB) ([]Migration, error) {
+	err := createTable(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	err = adopt(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := Pending(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for _, m := range pending {
+		ok, err := apply(ctx, db, m, true)
+		if err != nil {
+			return done, fmt.Errorf("migrations: %04d_%s up: %w", m.Version, m.Name, err)
+		}
+		if ok {
+			done = append(done, m)
+		}
+	}
+	return done, nil
+}
+
+// Down rolls back the given number of most recently applied migrations, or
+// all of them if steps is less than 1, and returns the ones it rolled back.
+func Down(ctx context.Context, db DB, steps int) ([]Migration, error) {
+	err := createTable(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := Applied(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for i := len(all) - 1; i >= 0; i-- {
+		if steps > 0 && len(done) == steps {
+			break
+		}
+		m := all[i]
+		if !applied[m.Version] {
+			continue
+		}
+		ok, err := apply(ctx, db, m, false)
+		if err != nil {
+			return done, fmt.Errorf("migrations: %04d_%s down: %w", m.Version, m.Name, err)
+		}
+		if ok {
+			done = append(done, m)
+		}
+	}
+	return done, nil
+}
+
+func apply(ctx context.Context, db DB, m Migration, up bool) (bool, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", lockID)
+	if err != nil {
+		return false, err
+	}
+
+	var exists bool
+	err = tx.QueryRow(ctx, "SELECT EXISTS(SELECT true FROM schema_migrations WHERE version = $1)", m.Version).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	if exists == up {
+		return false, nil
+	}
+
+	if up {
+		_, err = tx.Exec(ctx, m.Up)
+		if err != nil {
+			return false, err
+		}
+		stmt := `
+    INSERT INTO schema_migrations (version, name, applied)
+    VALUES($1, $2, CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+  `
+		_, err = tx.Exec(ctx, stmt, m.Version, m.Name)
+	} else {
+		_, err = tx.Exec(ctx, m.Down)
+		if err != nil {
+			return false, err
+		}
+		_, err = tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1", m.Version)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/purple-mountain/snippetbox/internal/assert"
+)
+
+func TestAll(t *testing.T) {
+	migrations, err := All()
+	assert.NilError(t, err)
+	if len(migrations) == 0 {
+		t.Fatal("no migrations found")
+	}
+
+	for i, m := range migrations {
+		assert.Equal(t, m.Version, i+1)
+		if m.Up == "" || m.Down == "" {
+			t.Errorf("migration %d is missing a script", m.Version)
+		}
+	}
+	assert.Equal(t, migrations[0].Name, "initial_schema")
+	assert.Equal(t, migrations[1].Name, "snippet_authors")
+	assert.Equal(t, migrations[7].Name, "sessions")
+}
@@ -0,0 +1,5 @@
+-- Snippets from before authors were recorded keep a NULL user_id and are
+-- shown as anonymous.
+ALTER TABLE snippets ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_snippets_user_id ON snippets(user_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS snippet_revisions;
+
+ALTER TABLE snippets DROP COLUMN IF EXISTS updated;
+ALTER TABLE snippets DROP COLUMN IF EXISTS version;
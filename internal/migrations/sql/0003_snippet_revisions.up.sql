@@ -0,0 +1,16 @@
+ALTER TABLE snippets ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE snippets ADD COLUMN updated TIMESTAMP WITH TIME ZONE;
+
+UPDATE snippets SET updated = created;
+
+ALTER TABLE snippets ALTER COLUMN updated SET NOT NULL;
+
+CREATE TABLE snippet_revisions (
+  id SERIAL PRIMARY KEY,
+  snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+  version INTEGER NOT NULL,
+  title VARCHAR(100) NOT NULL,
+  content TEXT NOT NULL,
+  created TIMESTAMP WITH TIME ZONE NOT NULL,
+  CONSTRAINT snippet_revisions_uc_version UNIQUE (snippet_id, version)
+);
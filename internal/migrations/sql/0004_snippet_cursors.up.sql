@@ -0,0 +1,3 @@
+DROP INDEX idx_snippets_created;
+
+CREATE INDEX idx_snippets_created ON snippets(created, id);
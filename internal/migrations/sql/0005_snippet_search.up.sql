@@ -0,0 +1,5 @@
+ALTER TABLE snippets ADD COLUMN search TSVECTOR GENERATED ALWAYS AS (
+  setweight(to_tsvector('english', title), 'A') || setweight(to_tsvector('english', content), 'B')
+) STORED;
+
+CREATE INDEX idx_snippets_search ON snippets USING GIN(search);
@@ -0,0 +1,3 @@
+ALTER TABLE snippet_revisions DROP COLUMN IF EXISTS language;
+
+ALTER TABLE snippets DROP COLUMN IF EXISTS language;
@@ -0,0 +1,3 @@
+ALTER TABLE snippets ADD COLUMN language VARCHAR(32) NOT NULL DEFAULT '';
+
+ALTER TABLE snippet_revisions ADD COLUMN language VARCHAR(32) NOT NULL DEFAULT '';
@@ -0,0 +1,13 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS verified_at TIMESTAMP WITH TIME ZONE;
+
+-- Accounts created before verification existed keep working.
+UPDATE users SET verified_at = created WHERE verified_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS tokens (
+  hash BYTEA PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  scope VARCHAR(32) NOT NULL,
+  expiry TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_tokens_user_id ON tokens(user_id, scope);
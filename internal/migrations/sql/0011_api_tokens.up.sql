@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS api_tokens (
+  id SERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name VARCHAR(100) NOT NULL,
+  hash BYTEA NOT NULL,
+  scope VARCHAR(16) NOT NULL,
+  created TIMESTAMP WITH TIME ZONE NOT NULL,
+  last_used TIMESTAMP WITH TIME ZONE,
+  CONSTRAINT api_tokens_uc_hash UNIQUE (hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens(user_id);
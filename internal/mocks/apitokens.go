@@ -0,0 +1,65 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/purple-mountain/snippetbox/internal/models"
+)
+
+var mockAPIToken = &models.APIToken{
+	ID:      1,
+	UserID:  1,
+	Name:    "CI",
+	Scope:   models.ScopeWrite,
+	Created: time.Now(),
+}
+
+var mockReadAPIToken = &models.APIToken{
+	ID:      2,
+	UserID:  1,
+	Name:    "Backups",
+	Scope:   models.ScopeRead,
+	Created: time.Now(),
+}
+
+var mockUnverifiedAPIToken = &models.APIToken{
+	ID:      3,
+	UserID:  2,
+	Name:    "Laptop",
+	Scope:   models.ScopeWrite,
+	Created: time.Now(),
+}
+
+type APITokenModel struct{}
+
+func (m *APITokenModel) Insert(ctx context.Context, userID int, name, scope string) (string, error) {
+	return "sbx_new", nil
+}
+
+func (m *APITokenModel) ByUser(ctx context.Context, userID int) ([]*models.APIToken, error) {
+	if userID == 1 {
+		return []*models.APIToken{mockReadAPIToken, mockAPIToken}, nil
+	}
+	return []*models.APIToken{}, nil
+}
+
+func (m *APITokenModel) Delete(ctx context.Context, id, userID int) error {
+	if userID == 1 && (id == 1 || id == 2) {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *APITokenModel) Authenticate(ctx context.Context, token string) (*models.APIToken, error) {
+	switch token {
+	case "sbx_write":
+		return mockAPIToken, nil
+	case "sbx_read":
+		return mockReadAPIToken, nil
+	case "sbx_unverified":
+		return mockUnverifiedAPIToken, nil
+	default:
+		return nil, models.ErrInvalidToken
+	}
+}
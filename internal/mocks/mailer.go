@@ -0,0 +1,26 @@
+package mocks
+
+import (
+	"sync"
+
+	"github.com/purple-mountain/snippetbox/internal/mailer"
+)
+
+// Mailer records the messages it is asked to send.
+type Mailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (m *Mailer) Send(msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *Mailer) Sent() []mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]mailer.Message(nil), m.sent...)
+}
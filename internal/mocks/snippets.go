@@ -1,34 +1,197 @@
 package mocks
 
 import (
+	"context"
+	"strings"
 	"time"
 
 	"github.com/purple-mountain/snippetbox/internal/models"
 )
 
 var mockSnippet = &models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
+	ID:       1,
+	UserID:   1,
+	Author:   "Alice",
+	Title:    "An old silent pond",
+	Content:  "An old silent pond...",
+	Language: "",
+	Version:  2,
+	Created:  time.Now(),
+	Updated:  time.Now(),
+	Expires:  time.Now(),
+}
+
+var mockRevision = &models.Revision{
+	SnippetID: 1,
+	Version:   1,
+	Title:     "An old pond",
+	Content:   "An old pond...",
+	Created:   time.Now(),
+}
+
+var mockForeignSnippet = &models.Snippet{
+	ID:                 3,
+	UserID:             2,
+	Author:             "Bob",
+	Title:              "Over the wintry forest",
+	Content:            "Over the wintry forest, winds howl in rage...",
+	Language:           "markdown",
+	LanguageConfidence: 0.6,
+	Version:            1,
+	Created:            time.Now(),
+	Updated:            time.Now(),
+	Expires:            time.Now(),
+}
+
+var mockAnonymousSnippet = &models.Snippet{
+	ID:      4,
+	Author:  "anonymous",
+	Title:   "First autumn morning",
+	Content: "First autumn morning: the mirror I stare into shows my father's face.",
+	Version: 1,
 	Created: time.Now(),
+	Updated: time.Now(),
 	Expires: time.Now(),
 }
 
 type SnippetModel struct{}
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title string, content string, language string, confidence float64, expires int) (int, error) {
 	return 2, nil
 }
 
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+func (m *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
+	case 3:
+		return mockForeignSnippet, nil
+	case 4:
+		return mockAnonymousSnippet, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
 }
 
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-	return []*models.Snippet{mockSnippet}, nil
+func (m *SnippetModel) Latest(ctx context.Context, q models.PageQuery) (*models.SnippetPage, error) {
+	if q.Before != nil || q.After != nil {
+		return &models.SnippetPage{Snippets: []*models.Snippet{}}, nil
+	}
+	return &models.SnippetPage{Snippets: []*models.Snippet{mockSnippet}}, nil
+}
+
+func (m *SnippetModel) ByUser(ctx context.Context, userID int) ([]*models.Snippet, error) {
+	switch userID {
+	case 1:
+		return []*models.Snippet{mockSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) Update(ctx context.Context, id int, title string, content string, language string, confidence float64) error {
+	switch id {
+	case 1, 3:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	switch id {
+	case 1, 3:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Revisions(ctx context.Context, id int) ([]*models.Revision, error) {
+	switch id {
+	case 1:
+		return []*models.Revision{currentRevision(mockSnippet), mockRevision}, nil
+	default:
+		return []*models.Revision{}, nil
+	}
+}
+
+func (m *SnippetModel) Revision(ctx context.Context, id int, version int) (*models.Revision, error) {
+	if id != 1 {
+		return nil, models.ErrNoRecord
+	}
+	switch version {
+	case 1:
+		return mockRevision, nil
+	case 2:
+		return currentRevision(mockSnippet), nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func currentRevision(s *models.Snippet) *models.Revision {
+	return &models.Revision{
+		SnippetID: s.ID,
+		Version:   s.Version,
+		Title:     s.Title,
+		Content:   s.Content,
+		Language:  s.Language,
+		Created:   s.Updated,
+	}
+}
+
+func (m *SnippetModel) Search(ctx context.Context, query string, page int) (*models.SearchResults, error) {
+	terms := strings.Fields(strings.ToLower(query))
+	results := &models.SearchResults{Query: query, Page: page, Results: []*models.SearchResult{}}
+	if len(terms) == 0 {
+		return results, nil
+	}
+
+	var matches []*models.SearchResult
+	for _, s := range []*models.Snippet{mockSnippet, mockForeignSnippet} {
+		text := strings.ToLower(s.Title + " " + s.Content)
+		found := true
+		for _, term := range terms {
+			if !strings.Contains(text, term) {
+				found = false
+				break
+			}
+		}
+		if found {
+			matches = append(matches, &models.SearchResult{
+				Snippet:      s,
+				TitleMatch:   highlight(s.Title, terms),
+				ContentMatch: highlight(s.Content, terms),
+			})
+		}
+	}
+
+	results.Total = len(matches)
+	start := (page - 1) * models.SearchPageSize
+	if start < len(matches) {
+		results.Results = matches[start:min(start+models.SearchPageSize, len(matches))]
+	}
+	return results, nil
+}
+
+func highlight(text string, terms []string) string {
+	var b strings.Builder
+	lower := strings.ToLower(text)
+	for i := 0; i < len(text); {
+		matched := false
+		for _, term := range terms {
+			if strings.HasPrefix(lower[i:], term) {
+				b.WriteString(models.HighlightStart + text[i:i+len(term)] + models.HighlightStop)
+				i += len(term)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteByte(text[i])
+			i++
+		}
+	}
+	return b.String()
 }
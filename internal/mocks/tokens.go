@@ -0,0 +1,16 @@
+package mocks
+
+import (
+	"context"
+	"time"
+)
+
+type TokenModel struct{}
+
+func (m *TokenModel) New(ctx context.Context, userID int, ttl time.Duration, scope string) (string, error) {
+	return "valid-token", nil
+}
+
+func (m *TokenModel) DeleteAllForUser(ctx context.Context, userID int, scope string) error {
+	return nil
+}
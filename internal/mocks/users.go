@@ -1,58 +1,153 @@
 package mocks
 
 import (
+	"context"
+	"sync"
 	"time"
 
 	"github.com/purple-mountain/snippetbox/internal/models"
 )
 
-type UserModel struct{}
+// UserModel keeps the session epochs, so that tests can see other sessions
+// being logged out when the password changes.
+type UserModel struct {
+	mu     sync.Mutex
+	epochs map[int]int
+}
+
+func (m *UserModel) bumpEpoch(id int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.epochs == nil {
+		m.epochs = map[int]int{}
+	}
+	m.epochs[id]++
+}
 
-func (m *UserModel) Insert(name, email, password string) error {
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) (int, error) {
 	switch email {
 	case "dupe@example.com":
-		return models.ErrDuplicateEmail
+		return 0, models.ErrDuplicateEmail
 	default:
-		return nil
+		return 3, nil
 	}
 }
 
-func (m *UserModel) Authenticate(email, password string) (int, error) {
-	if email == "alice@example.com" && password == "pa$$word" {
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	switch {
+	case email == "alice@example.com" && password == "pa$$word":
 		return 1, nil
+	case email == "bob@example.com" && password == "pa$$word":
+		return 2, nil
+	case email == "carol@example.com" && password == "pa$$word":
+		return 4, nil
+	default:
+		return 0, models.ErrInvalidCredentials
 	}
-	return 0, models.ErrInvalidCredentials
 }
 
-func (m *UserModel) Exists(id int) (bool, error) {
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
 	switch id {
-	case 1:
+	case 1, 2, 4:
 		return true, nil
 	default:
 		return false, nil
 	}
 }
 
-func (m *UserModel) GetUser(id int) (*models.User, error) {
+func (m *UserModel) SessionEpoch(ctx context.Context, id int) (int, error) {
+	switch id {
+	case 1, 2, 4:
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return m.epochs[id], nil
+	default:
+		return 0, models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) GetUser(ctx context.Context, id int) (*models.User, error) {
 	switch id {
 	case 1:
 		return &models.User{
-			ID:      1,
-			Name:    "Alice",
-			Email:   "alice@example.com",
+			ID:       1,
+			Name:     "Alice",
+			Email:    "alice@example.com",
+			Created:  time.Now(),
+			Verified: true,
+		}, nil
+	case 2:
+		return &models.User{
+			ID:      2,
+			Name:    "Bob",
+			Email:   "bob@example.com",
 			Created: time.Now(),
 		}, nil
+	case 4:
+		return &models.User{
+			ID:        4,
+			Name:      "Carol",
+			Email:     "carol@example.com",
+			Created:   time.Now(),
+			Verified:  true,
+			TwoFactor: true,
+		}, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
 }
 
-func (m *UserModel) UpdatePassword(id int, currentPassword, newPassword string) error {
+func (m *UserModel) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	switch email {
+	case "alice@example.com":
+		return m.GetUser(ctx, 1)
+	case "bob@example.com":
+		return m.GetUser(ctx, 2)
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) UpdatePassword(ctx context.Context, id int, currentPassword, newPassword string) error {
 	if id != 1 {
 		return models.ErrNoRecord
 	}
 	if currentPassword != "pa$$word" {
 		return models.ErrInvalidCredentials
 	}
+	m.bumpEpoch(id)
+	return nil
+}
+
+func (m *UserModel) Verify(ctx context.Context, token string) (int, error) {
+	if token == "valid-token" {
+		return 2, nil
+	}
+	return 0, models.ErrInvalidToken
+}
+
+func (m *UserModel) ResetPassword(ctx context.Context, token, newPassword string) (int, error) {
+	if token == "valid-token" {
+		m.bumpEpoch(1)
+		return 1, nil
+	}
+	return 0, models.ErrInvalidToken
+}
+
+func (m *UserModel) EnableTwoFactor(ctx context.Context, id int, secret string) ([]string, error) {
+	if id != 1 {
+		return nil, models.ErrNoRecord
+	}
+	return []string{"AAAA-BBBB-CCCC-DDDD", "EEEE-FFFF-GGGG-HHHH"}, nil
+}
+
+func (m *UserModel) DisableTwoFactor(ctx context.Context, id int) error {
 	return nil
 }
+
+func (m *UserModel) VerifySecondFactor(ctx context.Context, id int, code string) error {
+	if id == 4 && (code == "123456" || code == "AAAA-BBBB-CCCC-DDDD") {
+		return nil
+	}
+	return models.ErrInvalidCredentials
+}
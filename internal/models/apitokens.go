@@ -0,0 +1,142 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+
+	// apiTokenPrefix makes leaked tokens easy to recognise for secret scanners.
+	apiTokenPrefix = "sbx_"
+)
+
+type APIToken struct {
+	ID       int
+	UserID   int
+	Name     string
+	Scope    string
+	Created  time.Time
+	LastUsed time.Time
+}
+
+type APITokenModel struct {
+	DB      DB
+	Timeout time.Duration
+}
+
+type APITokenModelInterface interface {
+	Insert(ctx context.Context, userID int, name, scope string) (string, error)
+	ByUser(ctx context.Context, userID int) ([]*APIToken, error)
+	Delete(ctx context.Context, id, userID int) error
+	Authenticate(ctx context.Context, token string) (*APIToken, error)
+}
+
+// Insert creates a token for the user and returns its plaintext. Only a hash
+// is stored, so the plaintext cannot be shown again.
+func (m *APITokenModel) Insert(ctx context.Context, userID int, name, scope string) (string, error) {
+	ctx, cancel := queryContext(ctx, "APITokenModel.Insert", m.Timeout)
+	defer cancel()
+
+	b := make([]byte, 20)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	plaintext := apiTokenPrefix + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+
+	stmt := `
+    INSERT INTO api_tokens (user_id, name, hash, scope, created)
+    VALUES($1, $2, $3, $4, CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+  `
+	_, err = m.DB.Exec(ctx, stmt, userID, name, hashToken(plaintext), scope)
+	if err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
+
+func (m *APITokenModel) ByUser(ctx context.Context, userID int) ([]*APIToken, error) {
+	ctx, cancel := queryContext(ctx, "APITokenModel.ByUser", m.Timeout)
+	defer cancel()
+
+	stmt := `
+    SELECT id, user_id, name, scope, created, last_used FROM api_tokens
+    WHERE user_id = $1 ORDER BY id DESC
+  `
+	rows, err := m.DB.Query(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*APIToken{}
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (m *APITokenModel) Delete(ctx context.Context, id, userID int) error {
+	ctx, cancel := queryContext(ctx, "APITokenModel.Delete", m.Timeout)
+	defer cancel()
+
+	stmt := `DELETE FROM api_tokens WHERE id = $1 AND user_id = $2`
+	result, err := m.DB.Exec(ctx, stmt, id, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// Authenticate looks up the token and records that it has been used. It
+// returns ErrInvalidToken if the token does not exist or has been revoked.
+func (m *APITokenModel) Authenticate(ctx context.Context, token string) (*APIToken, error) {
+	ctx, cancel := queryContext(ctx, "APITokenModel.Authenticate", m.Timeout)
+	defer cancel()
+
+	stmt := `
+    UPDATE api_tokens SET last_used = CURRENT_TIMESTAMP AT TIME ZONE 'UTC'
+    WHERE hash = $1
+    RETURNING id, user_id, name, scope, created, last_used
+  `
+	t, err := scanAPIToken(m.DB.QueryRow(ctx, stmt, hashToken(token)))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+func scanAPIToken(row pgx.Row) (*APIToken, error) {
+	var t APIToken
+	var lastUsed *time.Time
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Created, &lastUsed)
+	if err != nil {
+		return nil, err
+	}
+	if lastUsed != nil {
+		t.LastUsed = *lastUsed
+	}
+	return &t, nil
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/purple-mountain/snippetbox/internal/assert"
+)
+
+func TestAPITokenModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: Skipping integration test")
+	}
+	ctx := context.Background()
+	db := newTestDB(t)
+	tokens := APITokenModel{DB: db}
+
+	plaintext, err := tokens.Insert(ctx, 1, "CI", ScopeWrite)
+	assert.NilError(t, err)
+	assert.Equal(t, strings.HasPrefix(plaintext, apiTokenPrefix), true)
+
+	token, err := tokens.Authenticate(ctx, plaintext)
+	assert.NilError(t, err)
+	assert.Equal(t, token.UserID, 1)
+	assert.Equal(t, token.Scope, ScopeWrite)
+	assert.Equal(t, token.LastUsed.IsZero(), false)
+
+	list, err := tokens.ByUser(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(list), 1)
+
+	assert.Equal(t, tokens.Delete(ctx, token.ID, 2), ErrNoRecord)
+	assert.NilError(t, tokens.Delete(ctx, token.ID, 1))
+	_, err = tokens.Authenticate(ctx, plaintext)
+	assert.Equal(t, err, ErrInvalidToken)
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	TooManyConnections = "53300"
+	AdminShutdown      = "57P01"
+	CannotConnectNow   = "57P03"
+)
+
+// DB is the subset of *pgxpool.Pool used by the models. Every method is safe
+// for concurrent use, unlike a single *pgx.Conn.
+type DB interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+type methodContextKey struct{}
+
+// Method returns the model method, such as "SnippetModel.Get", that issued
+// the queries running under ctx. It is meant for a pgx.QueryTracer.
+func Method(ctx context.Context) string {
+	method, _ := ctx.Value(methodContextKey{}).(string)
+	return method
+}
+
+func queryContext(ctx context.Context, method string, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx = context.WithValue(ctx, methodContextKey{}, method)
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// IsTimeout reports whether err was caused by a query running past its
+// deadline.
+func IsTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err)
+}
+
+// IsUnavailable reports whether err means the database could not be reached
+// or refused to serve the query, as opposed to a bug in the query itself.
+func IsUnavailable(err error) bool {
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.HasPrefix(pgErr.Code, "08") || pgErr.Code == TooManyConnections || pgErr.Code == AdminShutdown ||
+			pgErr.Code == CannotConnectNow
+	}
+	return false
+}
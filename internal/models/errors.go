@@ -6,4 +6,6 @@ var (
 	ErrNoRecord           = errors.New("models: no matching record found")
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
+	ErrInvalidCursor      = errors.New("models: invalid pagination cursor")
+	ErrInvalidToken       = errors.New("models: invalid or expired token")
 )
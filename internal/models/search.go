@@ -0,0 +1,92 @@
+package models
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	HighlightStart = "\ue000"
+	HighlightStop  = "\ue001"
+	SearchPageSize = 10
+	// SearchMaxPage is the deepest results page served; ranking further
+	// than this is wasted work and keeps the OFFSET small.
+	SearchMaxPage = 100
+)
+
+type SearchResult struct {
+	Snippet      *Snippet
+	TitleMatch   string
+	ContentMatch string
+}
+
+type SearchResults struct {
+	Query   string
+	Page    int
+	Total   int
+	Results []*SearchResult
+}
+
+func (r *SearchResults) HasPrevious() bool {
+	return r.Page > 1
+}
+
+func (r *SearchResults) HasNext() bool {
+	return r.Page < SearchMaxPage && r.Page*SearchPageSize < r.Total
+}
+
+func (r *SearchResults) Previous() int {
+	return r.Page - 1
+}
+
+func (r *SearchResults) Next() int {
+	return r.Page + 1
+}
+
+func (m *SnippetModel) Search(ctx context.Context, query string, page int) (*SearchResults, error) {
+	ctx, cancel := queryContext(ctx, "SnippetModel.Search", m.Timeout)
+	defer cancel()
+
+	stmt := `
+    SELECT m.id, m.user_id, m.name, m.title, m.content, m.language, m.language_confidence, m.version, m.created, m.updated, m.expires,
+      ts_headline('english', m.title, m.query, $2),
+      ts_headline('english', m.content, m.query, $3),
+      m.total
+    FROM (
+      SELECT s.id, COALESCE(s.user_id, 0) AS user_id, COALESCE(u.name, 'anonymous') AS name, s.title, s.content, s.language, s.language_confidence, s.version, s.created, s.updated, s.expires,
+        q AS query, ts_rank(s.search, q) AS rank, count(*) OVER () AS total
+      FROM snippets s LEFT JOIN users u ON u.id = s.user_id, websearch_to_tsquery('english', $1) q
+      WHERE s.expires > CURRENT_TIMESTAMP AT TIME ZONE 'UTC' AND s.search @@ q
+      ORDER BY rank DESC, s.id DESC
+      LIMIT $4 OFFSET $5
+    ) m
+    ORDER BY m.rank DESC, m.id DESC
+  `
+	titleOptions := fmt.Sprintf("StartSel=%s, StopSel=%s, HighlightAll=true", HighlightStart, HighlightStop)
+	contentOptions := fmt.Sprintf("StartSel=%s, StopSel=%s, MaxFragments=3, MaxWords=25, MinWords=10, FragmentDelimiter=\" … \"",
+		HighlightStart, HighlightStop)
+
+	rows, err := m.DB.Query(ctx, stmt, query, titleOptions, contentOptions,
+		SearchPageSize, (page-1)*SearchPageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := &SearchResults{Query: query, Page: page, Results: []*SearchResult{}}
+	for rows.Next() {
+		s := &Snippet{}
+		r := &SearchResult{Snippet: s}
+		err := rows.Scan(&s.ID, &s.UserID, &s.Author, &s.Title, &s.Content, &s.Language, &s.LanguageConfidence, &s.Version, &s.Created, &s.Updated, &s.Expires,
+			&r.TitleMatch, &r.ContentMatch, &results.Total)
+		if err != nil {
+			return nil, err
+		}
+		results.Results = append(results.Results, r)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
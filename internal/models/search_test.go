@@ -0,0 +1,41 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/purple-mountain/snippetbox/internal/assert"
+)
+
+func TestSearchResultsHasNext(t *testing.T) {
+	tests := []struct {
+		name  string
+		page  int
+		total int
+		want  bool
+	}{
+		{
+			name:  "More Results",
+			page:  1,
+			total: SearchPageSize + 1,
+			want:  true,
+		},
+		{
+			name:  "Last Page",
+			page:  2,
+			total: 2 * SearchPageSize,
+			want:  false,
+		},
+		{
+			name:  "Deepest Page",
+			page:  SearchMaxPage,
+			total: (SearchMaxPage + 1) * SearchPageSize,
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &SearchResults{Page: tt.page, Total: tt.total}
+			assert.Equal(t, r.HasNext(), tt.want)
+		})
+	}
+}
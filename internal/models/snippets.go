@@ -3,50 +3,122 @@ package models
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 )
 
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
+	ID                 int
+	UserID             int
+	Author             string
+	Title              string
+	Content            string
+	Language           string
+	LanguageConfidence float64
+	Version            int
+	Created            time.Time
+	Updated            time.Time
+	Expires            time.Time
+}
+
+type Revision struct {
+	SnippetID int
+	Version   int
+	Title     string
+	Content   string
+	Language  string
+	Created   time.Time
+}
+
+type Cursor struct {
 	Created time.Time
-	Expires time.Time
+	ID      int
+}
+
+func (c Cursor) String() string {
+	return fmt.Sprintf("%d-%d", c.Created.UnixMicro(), c.ID)
+}
+
+func ParseCursor(s string) (Cursor, error) {
+	created, id, ok := strings.Cut(s, "-")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+	micros, err := strconv.ParseInt(created, 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil || n < 1 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{Created: time.UnixMicro(micros).UTC(), ID: n}, nil
+}
+
+type PageQuery struct {
+	Before *Cursor
+	After  *Cursor
+	Limit  int
+}
+
+type SnippetPage struct {
+	Snippets []*Snippet
+	Older    *Cursor
+	Newer    *Cursor
 }
 
 type SnippetModel struct {
-	DB *pgx.Conn
+	DB      DB
+	Timeout time.Duration
 }
 
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
-	Get(id int) (*Snippet, error)
-	Latest() ([]*Snippet, error)
+	Insert(ctx context.Context, userID int, title string, content string, language string, confidence float64, expires int) (int, error)
+	Get(ctx context.Context, id int) (*Snippet, error)
+	Latest(ctx context.Context, q PageQuery) (*SnippetPage, error)
+	ByUser(ctx context.Context, userID int) ([]*Snippet, error)
+	Update(ctx context.Context, id int, title string, content string, language string, confidence float64) error
+	Delete(ctx context.Context, id int) error
+	Revisions(ctx context.Context, id int) ([]*Revision, error)
+	Revision(ctx context.Context, id int, version int) (*Revision, error)
+	Search(ctx context.Context, query string, page int) (*SearchResults, error)
 }
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title string, content string, language string, confidence float64, expires int) (int, error) {
+	ctx, cancel := queryContext(ctx, "SnippetModel.Insert", m.Timeout)
+	defer cancel()
+
 	stmt := `
-    INSERT INTO snippets (title, content, created, expires)
-    VALUES($1, $2, CURRENT_TIMESTAMP AT TIME ZONE 'UTC', CURRENT_TIMESTAMP AT TIME ZONE 'UTC' + INTERVAL '1 day' * $3)
+    INSERT INTO snippets (user_id, title, content, language, language_confidence, created, updated, expires)
+    VALUES($1, $2, $3, $4, $5, CURRENT_TIMESTAMP AT TIME ZONE 'UTC', CURRENT_TIMESTAMP AT TIME ZONE 'UTC',
+      CURRENT_TIMESTAMP AT TIME ZONE 'UTC' + INTERVAL '1 day' * $6)
     RETURNING id
   `
 	id := 0
-	err := m.DB.QueryRow(context.Background(), stmt, title, content, expires).Scan(&id)
+	err := m.DB.QueryRow(ctx, stmt, userID, title, content, language, confidence, expires).Scan(&id)
 	if err != nil {
 		return 0, err
 	}
 	return int(id), nil
 }
 
-func (m *SnippetModel) Get(id int) (*Snippet, error) {
+func (m *SnippetModel) Get(ctx context.Context, id int) (*Snippet, error) {
+	ctx, cancel := queryContext(ctx, "SnippetModel.Get", m.Timeout)
+	defer cancel()
+
 	stmt := `
-    SELECT id, title, content, created, expires FROM snippets 
-    WHERE expires > CURRENT_TIMESTAMP AT TIME ZONE 'UTC' AND id = $1
+    SELECT s.id, COALESCE(s.user_id, 0), COALESCE(u.name, 'anonymous'), s.title, s.content, s.language, s.language_confidence, s.version, s.created, s.updated, s.expires
+    FROM snippets s LEFT JOIN users u ON u.id = s.user_id
+    WHERE s.expires > CURRENT_TIMESTAMP AT TIME ZONE 'UTC' AND s.id = $1
   `
 	s := &Snippet{}
-	err := m.DB.QueryRow(context.Background(), stmt, id).Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	err := m.DB.QueryRow(ctx, stmt, id).Scan(
+		&s.ID, &s.UserID, &s.Author, &s.Title, &s.Content, &s.Language, &s.LanguageConfidence, &s.Version, &s.Created, &s.Updated, &s.Expires)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -57,27 +129,214 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 	return s, nil
 }
 
-func (m *SnippetModel) Latest() ([]*Snippet, error) {
+func (m *SnippetModel) Latest(ctx context.Context, q PageQuery) (*SnippetPage, error) {
+	ctx, cancel := queryContext(ctx, "SnippetModel.Latest", m.Timeout)
+	defer cancel()
+
+	var rows pgx.Rows
+	var err error
+	switch {
+	case q.After != nil:
+		stmt := `
+    SELECT s.id, COALESCE(s.user_id, 0), COALESCE(u.name, 'anonymous'), s.title, s.content, s.language, s.language_confidence, s.version, s.created, s.updated, s.expires
+    FROM snippets s LEFT JOIN users u ON u.id = s.user_id
+    WHERE s.expires > CURRENT_TIMESTAMP AT TIME ZONE 'UTC' AND (s.created, s.id) > ($1, $2)
+    ORDER BY s.created ASC, s.id ASC LIMIT $3
+  `
+		rows, err = m.DB.Query(ctx, stmt, q.After.Created, q.After.ID, q.Limit+1)
+	case q.Before != nil:
+		stmt := `
+    SELECT s.id, COALESCE(s.user_id, 0), COALESCE(u.name, 'anonymous'), s.title, s.content, s.language, s.language_confidence, s.version, s.created, s.updated, s.expires
+    FROM snippets s LEFT JOIN users u ON u.id = s.user_id
+    WHERE s.expires > CURRENT_TIMESTAMP AT TIME ZONE 'UTC' AND (s.created, s.id) < ($1, $2)
+    ORDER BY s.created DESC, s.id DESC LIMIT $3
+  `
+		rows, err = m.DB.Query(ctx, stmt, q.Before.Created, q.Before.ID, q.Limit+1)
+	default:
+		stmt := `
+    SELECT s.id, COALESCE(s.user_id, 0), COALESCE(u.name, 'anonymous'), s.title, s.content, s.language, s.language_confidence, s.version, s.created, s.updated, s.expires
+    FROM snippets s LEFT JOIN users u ON u.id = s.user_id
+    WHERE s.expires > CURRENT_TIMESTAMP AT TIME ZONE 'UTC'
+    ORDER BY s.created DESC, s.id DESC LIMIT $1
+  `
+		rows, err = m.DB.Query(ctx, stmt, q.Limit+1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	snippets, err := scanSnippets(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	more := len(snippets) > q.Limit
+	if more {
+		snippets = snippets[:q.Limit]
+	}
+	if q.After != nil {
+		slices.Reverse(snippets)
+	}
+
+	page := &SnippetPage{Snippets: snippets}
+	if len(snippets) == 0 {
+		return page, nil
+	}
+	first, last := snippets[0], snippets[len(snippets)-1]
+	if q.After != nil {
+		page.Older = &Cursor{Created: last.Created, ID: last.ID}
+		if more {
+			page.Newer = &Cursor{Created: first.Created, ID: first.ID}
+		}
+	} else {
+		if q.Before != nil {
+			page.Newer = &Cursor{Created: first.Created, ID: first.ID}
+		}
+		if more {
+			page.Older = &Cursor{Created: last.Created, ID: last.ID}
+		}
+	}
+	return page, nil
+}
+
+func (m *SnippetModel) ByUser(ctx context.Context, userID int) ([]*Snippet, error) {
+	ctx, cancel := queryContext(ctx, "SnippetModel.ByUser", m.Timeout)
+	defer cancel()
+
 	stmt := `
-    SELECT id, title, content, created, expires FROM snippets 
-    WHERE expires > CURRENT_TIMESTAMP AT TIME ZONE 'UTC' ORDER BY id DESC LIMIT 10
+    SELECT s.id, COALESCE(s.user_id, 0), COALESCE(u.name, 'anonymous'), s.title, s.content, s.language, s.language_confidence, s.version, s.created, s.updated, s.expires
+    FROM snippets s LEFT JOIN users u ON u.id = s.user_id
+    WHERE s.expires > CURRENT_TIMESTAMP AT TIME ZONE 'UTC' AND s.user_id = $1 ORDER BY s.id DESC
   `
-	snippets := []*Snippet{}
-	rows, err := m.DB.Query(context.Background(), stmt)
+	rows, err := m.DB.Query(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanSnippets(rows)
+}
+
+func (m *SnippetModel) Update(ctx context.Context, id int, title string, content string, language string, confidence float64) error {
+	ctx, cancel := queryContext(ctx, "SnippetModel.Update", m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	stmt := `
+    INSERT INTO snippet_revisions (snippet_id, version, title, content, language, created)
+    SELECT id, version, title, content, language, updated FROM snippets
+    WHERE expires > CURRENT_TIMESTAMP AT TIME ZONE 'UTC' AND id = $1
+    FOR UPDATE
+  `
+	result, err := tx.Exec(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	stmt = `
+    UPDATE snippets SET title = $1, content = $2, language = $3, language_confidence = $4, version = version + 1,
+      updated = CURRENT_TIMESTAMP AT TIME ZONE 'UTC'
+    WHERE id = $5
+  `
+	_, err = tx.Exec(ctx, stmt, title, content, language, confidence, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	ctx, cancel := queryContext(ctx, "SnippetModel.Delete", m.Timeout)
+	defer cancel()
+
+	stmt := `DELETE FROM snippets WHERE id = $1`
+	result, err := m.DB.Exec(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+func (m *SnippetModel) Revisions(ctx context.Context, id int) ([]*Revision, error) {
+	ctx, cancel := queryContext(ctx, "SnippetModel.Revisions", m.Timeout)
+	defer cancel()
+
+	stmt := `
+    SELECT s.id, s.version, s.title, s.content, s.language, s.updated FROM snippets s
+    WHERE s.expires > CURRENT_TIMESTAMP AT TIME ZONE 'UTC' AND s.id = $1
+    UNION ALL
+    SELECT r.snippet_id, r.version, r.title, r.content, r.language, r.created
+    FROM snippet_revisions r INNER JOIN snippets s ON s.id = r.snippet_id
+    WHERE s.expires > CURRENT_TIMESTAMP AT TIME ZONE 'UTC' AND s.id = $1
+    ORDER BY version DESC
+  `
+	rows, err := m.DB.Query(ctx, stmt, id)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	revisions := []*Revision{}
+	for rows.Next() {
+		r := &Revision{}
+		err := rows.Scan(&r.SnippetID, &r.Version, &r.Title, &r.Content, &r.Language, &r.Created)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (m *SnippetModel) Revision(ctx context.Context, id int, version int) (*Revision, error) {
+	ctx, cancel := queryContext(ctx, "SnippetModel.Revision", m.Timeout)
+	defer cancel()
+
+	stmt := `
+    SELECT s.id, s.version, s.title, s.content, s.language, s.updated FROM snippets s
+    WHERE s.expires > CURRENT_TIMESTAMP AT TIME ZONE 'UTC' AND s.id = $1 AND s.version = $2
+    UNION ALL
+    SELECT r.snippet_id, r.version, r.title, r.content, r.language, r.created
+    FROM snippet_revisions r INNER JOIN snippets s ON s.id = r.snippet_id
+    WHERE s.expires > CURRENT_TIMESTAMP AT TIME ZONE 'UTC' AND s.id = $1 AND r.version = $2
+  `
+	r := &Revision{}
+	err := m.DB.QueryRow(ctx, stmt, id, version).Scan(&r.SnippetID, &r.Version, &r.Title, &r.Content, &r.Language, &r.Created)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func scanSnippets(rows pgx.Rows) ([]*Snippet, error) {
+	defer rows.Close()
+
+	snippets := []*Snippet{}
 	for rows.Next() {
 		s := &Snippet{}
-		err := rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err := rows.Scan(&s.ID, &s.UserID, &s.Author, &s.Title, &s.Content, &s.Language, &s.LanguageConfidence, &s.Version, &s.Created, &s.Updated, &s.Expires)
 		if err != nil {
 			return nil, err
 		}
 		snippets = append(snippets, s)
 	}
-	err = rows.Err()
+	err := rows.Err()
 	if err != nil {
 		return nil, err
 	}
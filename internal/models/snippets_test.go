@@ -0,0 +1,203 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/purple-mountain/snippetbox/internal/assert"
+)
+
+func TestCursor(t *testing.T) {
+	t.Run("Round Trip", func(t *testing.T) {
+		c := Cursor{Created: time.Date(2022, 3, 17, 10, 15, 0, 123456000, time.UTC), ID: 42}
+		parsed, err := ParseCursor(c.String())
+		assert.NilError(t, err)
+		assert.Equal(t, parsed.ID, c.ID)
+		assert.Equal(t, parsed.Created.Equal(c.Created), true)
+	})
+
+	invalid := []string{"", "foo", "123", "123-", "-5", "abc-5", "123-0", "123-x"}
+	for _, s := range invalid {
+		t.Run("Invalid "+s, func(t *testing.T) {
+			_, err := ParseCursor(s)
+			assert.Equal(t, err, ErrInvalidCursor)
+		})
+	}
+}
+
+func TestSnippetModelDelete(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: Skipping integration test")
+	}
+	ctx := context.Background()
+	db := newTestDB(t)
+	snippets := SnippetModel{DB: db}
+
+	id, err := snippets.Insert(ctx, 1, "An old silent pond", "An old silent pond...", "", 0, 7)
+	assert.NilError(t, err)
+	snippet, err := snippets.Get(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.UserID, 1)
+	assert.Equal(t, snippet.Author, "Alice Jones")
+
+	assert.NilError(t, snippets.Delete(ctx, id))
+	_, err = snippets.Get(ctx, id)
+	assert.Equal(t, err, ErrNoRecord)
+	assert.Equal(t, snippets.Delete(ctx, id), ErrNoRecord)
+}
+
+func TestSnippetModelUpdate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: Skipping integration test")
+	}
+	ctx := context.Background()
+	db := newTestDB(t)
+	snippets := SnippetModel{DB: db}
+
+	id, err := snippets.Insert(ctx, 1, "An old pond", "An old pond...", "", 0, 7)
+	assert.NilError(t, err)
+	err = snippets.Update(ctx, id, "An old silent pond", "An old silent pond...", "markdown", 1)
+	assert.NilError(t, err)
+
+	snippet, err := snippets.Get(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Version, 2)
+	assert.Equal(t, snippet.Title, "An old silent pond")
+	assert.Equal(t, snippet.Language, "markdown")
+
+	revisions, err := snippets.Revisions(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(revisions), 2)
+	assert.Equal(t, revisions[0].Version, 2)
+	assert.Equal(t, revisions[0].Title, "An old silent pond")
+	assert.Equal(t, revisions[1].Version, 1)
+	assert.Equal(t, revisions[1].Title, "An old pond")
+	assert.Equal(t, revisions[1].Language, "")
+
+	revision, err := snippets.Revision(ctx, id, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, revision.Content, "An old pond...")
+	revision, err = snippets.Revision(ctx, id, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, revision.Content, "An old silent pond...")
+	_, err = snippets.Revision(ctx, id, 3)
+	assert.Equal(t, err, ErrNoRecord)
+
+	expired, err := snippets.Insert(ctx, 1, "Gone", "Gone...", "", 0, 0)
+	assert.NilError(t, err)
+	err = snippets.Update(ctx, expired, "Back", "Back...", "", 0)
+	assert.Equal(t, err, ErrNoRecord)
+
+	assert.NilError(t, snippets.Delete(ctx, id))
+	revisions, err = snippets.Revisions(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(revisions), 0)
+}
+
+func TestSnippetModelLatest(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: Skipping integration test")
+	}
+	ctx := context.Background()
+	db := newTestDB(t)
+	snippets := SnippetModel{DB: db}
+
+	_, err := snippets.Insert(ctx, 1, "Expired", "Expired...", "", 0, 0)
+	assert.NilError(t, err)
+	var ids []int
+	for _, title := range []string{"One", "Two", "Three", "Four", "Five"} {
+		id, err := snippets.Insert(ctx, 1, title, title+"...", "", 0, 7)
+		assert.NilError(t, err)
+		ids = append(ids, id)
+	}
+
+	assertIDs := func(t *testing.T, page *SnippetPage, want ...int) {
+		t.Helper()
+		if len(page.Snippets) != len(want) {
+			t.Fatalf("got %d snippets; want %d", len(page.Snippets), len(want))
+		}
+		for i, s := range page.Snippets {
+			assert.Equal(t, s.ID, want[i])
+		}
+	}
+
+	first, err := snippets.Latest(ctx, PageQuery{Limit: 2})
+	assert.NilError(t, err)
+	assertIDs(t, first, ids[4], ids[3])
+	assert.Equal(t, first.Newer == nil, true)
+	assert.Equal(t, first.Older.ID, ids[3])
+
+	second, err := snippets.Latest(ctx, PageQuery{Before: first.Older, Limit: 2})
+	assert.NilError(t, err)
+	assertIDs(t, second, ids[2], ids[1])
+	assert.Equal(t, second.Newer.ID, ids[2])
+	assert.Equal(t, second.Older.ID, ids[1])
+
+	last, err := snippets.Latest(ctx, PageQuery{Before: second.Older, Limit: 2})
+	assert.NilError(t, err)
+	assertIDs(t, last, ids[0])
+	assert.Equal(t, last.Newer.ID, ids[0])
+	assert.Equal(t, last.Older == nil, true)
+
+	back, err := snippets.Latest(ctx, PageQuery{After: last.Newer, Limit: 2})
+	assert.NilError(t, err)
+	assertIDs(t, back, ids[2], ids[1])
+	assert.Equal(t, back.Newer.ID, ids[2])
+	assert.Equal(t, back.Older.ID, ids[1])
+
+	top, err := snippets.Latest(ctx, PageQuery{After: back.Newer, Limit: 2})
+	assert.NilError(t, err)
+	assertIDs(t, top, ids[4], ids[3])
+	assert.Equal(t, top.Newer == nil, true)
+	assert.Equal(t, top.Older.ID, ids[3])
+}
+
+func TestSnippetModelSearch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: Skipping integration test")
+	}
+	ctx := context.Background()
+	db := newTestDB(t)
+	snippets := SnippetModel{DB: db}
+
+	pond, err := snippets.Insert(ctx, 1, "An old silent pond", "A frog jumps into the pond, splash! Silence again.", "", 0, 7)
+	assert.NilError(t, err)
+	_, err = snippets.Insert(ctx, 1, "Over the wintry forest", "Winds howl in rage with no leaves to blow.", "", 0, 7)
+	assert.NilError(t, err)
+	_, err = snippets.Insert(ctx, 1, "Expired pond", "The pond has dried up.", "", 0, 0)
+	assert.NilError(t, err)
+
+	results, err := snippets.Search(ctx, "pond", 1)
+	assert.NilError(t, err)
+	assert.Equal(t, results.Total, 1)
+	if len(results.Results) != 1 {
+		t.Fatalf("got %d results; want 1", len(results.Results))
+	}
+	match := results.Results[0]
+	assert.Equal(t, match.Snippet.ID, pond)
+	assert.Equal(t, match.Snippet.Author, "Alice Jones")
+	assert.StringContains(t, match.TitleMatch, HighlightStart+"pond"+HighlightStop)
+	assert.StringContains(t, match.ContentMatch, HighlightStart+"pond"+HighlightStop)
+
+	results, err = snippets.Search(ctx, "mountain", 1)
+	assert.NilError(t, err)
+	assert.Equal(t, results.Total, 0)
+	assert.Equal(t, len(results.Results), 0)
+
+	for i := 0; i < SearchPageSize+2; i++ {
+		_, err := snippets.Insert(ctx, 1, "Cicadas", "The shrill of cicadas seeps into the rocks.", "", 0, 7)
+		assert.NilError(t, err)
+	}
+	results, err = snippets.Search(ctx, "cicada", 1)
+	assert.NilError(t, err)
+	assert.Equal(t, results.Total, SearchPageSize+2)
+	assert.Equal(t, len(results.Results), SearchPageSize)
+	assert.Equal(t, results.HasNext(), true)
+
+	results, err = snippets.Search(ctx, "cicada", 2)
+	assert.NilError(t, err)
+	assert.Equal(t, results.Total, SearchPageSize+2)
+	assert.Equal(t, len(results.Results), 2)
+	assert.Equal(t, results.HasNext(), false)
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"time"
+)
+
+const (
+	ScopeVerification  = "verification"
+	ScopePasswordReset = "password-reset"
+)
+
+type TokenModel struct {
+	DB      DB
+	Timeout time.Duration
+}
+
+type TokenModelInterface interface {
+	New(ctx context.Context, userID int, ttl time.Duration, scope string) (string, error)
+	DeleteAllForUser(ctx context.Context, userID int, scope string) error
+}
+
+// hashToken returns the value stored for a token. Only the hash is kept in the
+// database so that a leaked tokens table cannot be used to act as a user.
+func hashToken(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+// New stores a single-use token for the user and returns its plaintext, which
+// is valid for ttl.
+func (m *TokenModel) New(ctx context.Context, userID int, ttl time.Duration, scope string) (string, error) {
+	ctx, cancel := queryContext(ctx, "TokenModel.New", m.Timeout)
+	defer cancel()
+
+	b := make([]byte, 20)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	plaintext := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+
+	stmt := `
+    INSERT INTO tokens (hash, user_id, scope, expiry)
+    VALUES($1, $2, $3, $4)
+  `
+	_, err = m.DB.Exec(ctx, stmt, hashToken(plaintext), userID, scope, time.Now().Add(ttl))
+	if err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
+
+func (m *TokenModel) DeleteAllForUser(ctx context.Context, userID int, scope string) error {
+	ctx, cancel := queryContext(ctx, "TokenModel.DeleteAllForUser", m.Timeout)
+	defer cancel()
+
+	stmt := `DELETE FROM tokens WHERE user_id = $1 AND scope = $2`
+	_, err := m.DB.Exec(ctx, stmt, userID, scope)
+	return err
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/purple-mountain/snippetbox/internal/totp"
+)
+
+const recoveryCodeCount = 10
+
+// normalizeCode strips the separators users type or paste along with a code.
+func normalizeCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+// newRecoveryCode returns a random 80-bit code formatted as XXXX-XXXX-XXXX-XXXX.
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	s := base32.StdEncoding.EncodeToString(b)
+	return s[0:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:16], nil
+}
+
+// EnableTwoFactor stores the TOTP secret of the user, replacing any earlier
+// one, and returns a new set of single-use recovery codes. Only their hashes
+// are stored, so they cannot be shown again.
+func (m *UserModel) EnableTwoFactor(ctx context.Context, id int, secret string) ([]string, error) {
+	ctx, cancel := queryContext(ctx, "UserModel.EnableTwoFactor", m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	stmt := `UPDATE users SET totp_secret = $1, totp_last_step = NULL WHERE id = $2`
+	result, err := tx.Exec(ctx, stmt, secret, id)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 0 {
+		return nil, ErrNoRecord
+	}
+	_, err = tx.Exec(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i], err = newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		stmt := `INSERT INTO recovery_codes (user_id, hash) VALUES($1, $2)`
+		_, err = tx.Exec(ctx, stmt, id, hashToken(normalizeCode(codes[i])))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, tx.Commit(ctx)
+}
+
+func (m *UserModel) DisableTwoFactor(ctx context.Context, id int) error {
+	ctx, cancel := queryContext(ctx, "UserModel.DisableTwoFactor", m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "UPDATE users SET totp_secret = NULL, totp_last_step = NULL WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// VerifySecondFactor checks a TOTP code or a recovery code for the user and
+// returns ErrInvalidCredentials if it does not match. Both kinds of code can
+// only be used once.
+func (m *UserModel) VerifySecondFactor(ctx context.Context, id int, code string) error {
+	ctx, cancel := queryContext(ctx, "UserModel.VerifySecondFactor", m.Timeout)
+	defer cancel()
+
+	code = normalizeCode(code)
+	if len(code) != totp.Digits {
+		stmt := `DELETE FROM recovery_codes WHERE user_id = $1 AND hash = $2`
+		result, err := m.DB.Exec(ctx, stmt, id, hashToken(code))
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected() == 0 {
+			return ErrInvalidCredentials
+		}
+		return nil
+	}
+
+	var secret *string
+	stmt := `SELECT totp_secret FROM users WHERE id = $1`
+	err := m.DB.QueryRow(ctx, stmt, id).Scan(&secret)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+	if secret == nil {
+		return ErrInvalidCredentials
+	}
+	step, ok := totp.Validate(*secret, code, time.Now())
+	if !ok {
+		return ErrInvalidCredentials
+	}
+
+	stmt = `
+    UPDATE users SET totp_last_step = $1
+    WHERE id = $2 AND (totp_last_step IS NULL OR totp_last_step < $1)
+  `
+	result, err := m.DB.Exec(ctx, stmt, step, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
@@ -20,48 +20,67 @@ type User struct {
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
+	Verified       bool
+	TwoFactor      bool
 }
 
 type UserModel struct {
-	DB *pgx.Conn
+	DB         DB
+	Timeout    time.Duration
+	BcryptCost int
 }
 
 type UserModelInterface interface {
-	Insert(name, email, password string) error
-	Authenticate(email, password string) (int, error)
-	Exists(id int) (bool, error)
-	GetUser(id int) (*User, error)
-	UpdatePassword(id int, currentPassword, newPassword string) error
+	Insert(ctx context.Context, name, email, password string) (int, error)
+	Authenticate(ctx context.Context, email, password string) (int, error)
+	Exists(ctx context.Context, id int) (bool, error)
+	SessionEpoch(ctx context.Context, id int) (int, error)
+	GetUser(ctx context.Context, id int) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	UpdatePassword(ctx context.Context, id int, currentPassword, newPassword string) error
+	Verify(ctx context.Context, token string) (int, error)
+	ResetPassword(ctx context.Context, token, newPassword string) (int, error)
+	EnableTwoFactor(ctx context.Context, id int, secret string) ([]string, error)
+	DisableTwoFactor(ctx context.Context, id int) error
+	VerifySecondFactor(ctx context.Context, id int, code string) error
 }
 
-func (m *UserModel) Insert(name, email, password string) error {
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) (int, error) {
+	ctx, cancel := queryContext(ctx, "UserModel.Insert", m.Timeout)
+	defer cancel()
+
 	stmt := `
     INSERT INTO users (name, email, hashed_password, created)
     VALUES($1, $2, $3, CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+    RETURNING id
   `
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), m.BcryptCost)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	_, err = m.DB.Exec(context.Background(), stmt, name, email, string(hashedPassword))
+	var id int
+	err = m.DB.QueryRow(ctx, stmt, name, email, string(hashedPassword)).Scan(&id)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			if pgErr.Code == UniqueConstraintViolation {
-				return ErrDuplicateEmail
+				return 0, ErrDuplicateEmail
 			}
 		}
-		return err
+		return 0, err
 	}
-	return nil
+	return id, nil
 }
 
-func (m *UserModel) Authenticate(email, password string) (int, error) {
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	ctx, cancel := queryContext(ctx, "UserModel.Authenticate", m.Timeout)
+	defer cancel()
+
 	var id int
 	var hashedPassword []byte
 
 	stmt := "SELECT id, hashed_password FROM users WHERE email = $1"
-	err := m.DB.QueryRow(context.Background(), stmt, email).Scan(&id, &hashedPassword)
+	err := m.DB.QueryRow(ctx, stmt, email).Scan(&id, &hashedPassword)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return 0, ErrInvalidCredentials
@@ -80,17 +99,44 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	return id, nil
 }
 
-func (m *UserModel) Exists(id int) (bool, error) {
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
+	ctx, cancel := queryContext(ctx, "UserModel.Exists", m.Timeout)
+	defer cancel()
+
 	var exists bool
 	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = $1)"
-	err := m.DB.QueryRow(context.Background(), stmt, id).Scan(&exists)
+	err := m.DB.QueryRow(ctx, stmt, id).Scan(&exists)
 	return exists, err
 }
 
-func (m *UserModel) GetUser(id int) (*User, error) {
+// SessionEpoch returns the counter that is stored in the sessions of the user
+// when they log in. Changing the password increments it, which logs the user
+// out of every session that still holds the old value.
+func (m *UserModel) SessionEpoch(ctx context.Context, id int) (int, error) {
+	ctx, cancel := queryContext(ctx, "UserModel.SessionEpoch", m.Timeout)
+	defer cancel()
+
+	var epoch int
+	stmt := "SELECT session_epoch FROM users WHERE id = $1"
+	err := m.DB.QueryRow(ctx, stmt, id).Scan(&epoch)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+	return epoch, nil
+}
+
+func (m *UserModel) GetUser(ctx context.Context, id int) (*User, error) {
+	ctx, cancel := queryContext(ctx, "UserModel.GetUser", m.Timeout)
+	defer cancel()
+
 	var user User
-	stmt := `SELECT name, email, created FROM users WHERE id = $1`
-	err := m.DB.QueryRow(context.Background(), stmt, id).Scan(&user.Name, &user.Email, &user.Created)
+	stmt := `
+    SELECT id, name, email, created, verified_at IS NOT NULL, totp_secret IS NOT NULL FROM users WHERE id = $1
+  `
+	err := m.DB.QueryRow(ctx, stmt, id).Scan(&user.ID, &user.Name, &user.Email, &user.Created, &user.Verified, &user.TwoFactor)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return &user, ErrNoRecord
@@ -101,11 +147,32 @@ func (m *UserModel) GetUser(id int) (*User, error) {
 	return &user, nil
 }
 
-func (m *UserModel) UpdatePassword(id int, currentPassword, newPassword string) error {
+func (m *UserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, cancel := queryContext(ctx, "UserModel.GetByEmail", m.Timeout)
+	defer cancel()
+
+	var user User
+	stmt := `
+    SELECT id, name, email, created, verified_at IS NOT NULL, totp_secret IS NOT NULL FROM users WHERE email = $1
+  `
+	err := m.DB.QueryRow(ctx, stmt, email).Scan(&user.ID, &user.Name, &user.Email, &user.Created, &user.Verified, &user.TwoFactor)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (m *UserModel) UpdatePassword(ctx context.Context, id int, currentPassword, newPassword string) error {
+	ctx, cancel := queryContext(ctx, "UserModel.UpdatePassword", m.Timeout)
+	defer cancel()
+
 	var hashedCurrentPassword []byte
 
 	stmt := "SELECT hashed_password FROM users WHERE id = $1"
-	err := m.DB.QueryRow(context.Background(), stmt, id).Scan(&hashedCurrentPassword)
+	err := m.DB.QueryRow(ctx, stmt, id).Scan(&hashedCurrentPassword)
 	if err != nil {
 		return err
 	}
@@ -119,12 +186,102 @@ func (m *UserModel) UpdatePassword(id int, currentPassword, newPassword string)
 		}
 	}
 
-	hashedNewPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	hashedNewPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), m.BcryptCost)
 	if err != nil {
 		return err
 	}
 
-	stmt = `UPDATE users SET hashed_password=$1 WHERE id=$2`
-	_, err = m.DB.Exec(context.Background(), stmt, string(hashedNewPassword), id)
+	stmt = `UPDATE users SET hashed_password=$1, session_epoch = session_epoch + 1 WHERE id=$2`
+	_, err = m.DB.Exec(ctx, stmt, string(hashedNewPassword), id)
 	return err
 }
+
+// Verify consumes a verification token and marks the email address of its
+// user as verified. It returns ErrInvalidToken if the token is unknown, has
+// already been used or has expired.
+func (m *UserModel) Verify(ctx context.Context, token string) (int, error) {
+	ctx, cancel := queryContext(ctx, "UserModel.Verify", m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var id int
+	stmt := `
+    DELETE FROM tokens
+    WHERE hash = $1 AND scope = $2 AND expiry > now()
+    RETURNING user_id
+  `
+	err = tx.QueryRow(ctx, stmt, hashToken(token), ScopeVerification).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrInvalidToken
+		}
+		return 0, err
+	}
+
+	stmt = `UPDATE users SET verified_at = CURRENT_TIMESTAMP AT TIME ZONE 'UTC' WHERE id = $1 AND verified_at IS NULL`
+	_, err = tx.Exec(ctx, stmt, id)
+	if err != nil {
+		return 0, err
+	}
+	stmt = `DELETE FROM tokens WHERE user_id = $1 AND scope = $2`
+	_, err = tx.Exec(ctx, stmt, id, ScopeVerification)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit(ctx)
+}
+
+// ResetPassword consumes a password reset token and sets the password of its
+// user. Following the link proves the user owns the email address, so it is
+// marked as verified too. It returns ErrInvalidToken if the token is unknown,
+// has already been used or has expired.
+func (m *UserModel) ResetPassword(ctx context.Context, token, newPassword string) (int, error) {
+	ctx, cancel := queryContext(ctx, "UserModel.ResetPassword", m.Timeout)
+	defer cancel()
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), m.BcryptCost)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var id int
+	stmt := `
+    DELETE FROM tokens
+    WHERE hash = $1 AND scope = $2 AND expiry > now()
+    RETURNING user_id
+  `
+	err = tx.QueryRow(ctx, stmt, hashToken(token), ScopePasswordReset).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrInvalidToken
+		}
+		return 0, err
+	}
+
+	stmt = `
+    UPDATE users SET hashed_password = $1, session_epoch = session_epoch + 1,
+      verified_at = COALESCE(verified_at, CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+    WHERE id = $2
+  `
+	_, err = tx.Exec(ctx, stmt, string(hashedPassword), id)
+	if err != nil {
+		return 0, err
+	}
+	stmt = `DELETE FROM tokens WHERE user_id = $1 AND scope = $2`
+	_, err = tx.Exec(ctx, stmt, id, ScopePasswordReset)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit(ctx)
+}
@@ -1,9 +1,14 @@
 package models
 
 import (
+	"context"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/purple-mountain/snippetbox/internal/assert"
+	"github.com/purple-mountain/snippetbox/internal/totp"
 )
 
 func TestUserModelExists(t *testing.T) {
@@ -29,8 +34,8 @@ func TestUserModelExists(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			db := newTestDB(t)
-			m := UserModel{db}
-			exists, err := m.Exists(tt.userID)
+			m := UserModel{DB: db}
+			exists, err := m.Exists(context.Background(), tt.userID)
 			assert.Equal(t, exists, tt.want)
 			if exists {
 				assert.NilError(t, err)
@@ -38,3 +43,112 @@ func TestUserModelExists(t *testing.T) {
 		})
 	}
 }
+
+func TestUserModelVerify(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: Skipping integration test")
+	}
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	// Run behind UTC so a comparison against UTC wall-clock time would see
+	// the one hour token below as already expired.
+	cfg := db.Config()
+	cfg.ConnConfig.RuntimeParams["timezone"] = "America/Bogota"
+	behind, err := pgxpool.NewWithConfig(ctx, cfg)
+	assert.NilError(t, err)
+	defer behind.Close()
+
+	users := UserModel{DB: behind, BcryptCost: 4}
+	tokens := TokenModel{DB: behind}
+
+	id, err := users.Insert(ctx, "Bob", "bob@example.com", "pa$$word")
+	assert.NilError(t, err)
+	user, err := users.GetUser(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Verified, false)
+
+	expired, err := tokens.New(ctx, id, -time.Minute, ScopeVerification)
+	assert.NilError(t, err)
+	_, err = users.Verify(ctx, expired)
+	assert.Equal(t, err, ErrInvalidToken)
+
+	token, err := tokens.New(ctx, id, time.Hour, ScopeVerification)
+	assert.NilError(t, err)
+	verifiedID, err := users.Verify(ctx, token)
+	assert.NilError(t, err)
+	assert.Equal(t, verifiedID, id)
+
+	user, err = users.GetUser(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Verified, true)
+
+	_, err = users.Verify(ctx, token)
+	assert.Equal(t, err, ErrInvalidToken)
+}
+
+func TestUserModelResetPassword(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: Skipping integration test")
+	}
+	ctx := context.Background()
+	db := newTestDB(t)
+	users := UserModel{DB: db, BcryptCost: 4}
+	tokens := TokenModel{DB: db}
+
+	user, err := users.GetByEmail(ctx, "alice@example.com")
+	assert.NilError(t, err)
+	_, err = users.GetByEmail(ctx, "nobody@example.com")
+	assert.Equal(t, err, ErrNoRecord)
+
+	verification, err := tokens.New(ctx, user.ID, time.Hour, ScopeVerification)
+	assert.NilError(t, err)
+	_, err = users.ResetPassword(ctx, verification, "new-pa$$word")
+	assert.Equal(t, err, ErrInvalidToken)
+
+	epoch, err := users.SessionEpoch(ctx, user.ID)
+	assert.NilError(t, err)
+	token, err := tokens.New(ctx, user.ID, time.Hour, ScopePasswordReset)
+	assert.NilError(t, err)
+	id, err := users.ResetPassword(ctx, token, "new-pa$$word")
+	assert.NilError(t, err)
+	assert.Equal(t, id, user.ID)
+	newEpoch, err := users.SessionEpoch(ctx, user.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, newEpoch, epoch+1)
+
+	_, err = users.Authenticate(ctx, "alice@example.com", "new-pa$$word")
+	assert.NilError(t, err)
+	_, err = users.ResetPassword(ctx, token, "other-pa$$word")
+	assert.Equal(t, err, ErrInvalidToken)
+}
+
+func TestUserModelTwoFactor(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: Skipping integration test")
+	}
+	ctx := context.Background()
+	db := newTestDB(t)
+	users := UserModel{DB: db}
+
+	secret, err := totp.GenerateSecret()
+	assert.NilError(t, err)
+	codes, err := users.EnableTwoFactor(ctx, 1, secret)
+	assert.NilError(t, err)
+	assert.Equal(t, len(codes), recoveryCodeCount)
+	user, err := users.GetUser(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, user.TwoFactor, true)
+
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	assert.NilError(t, err)
+	assert.NilError(t, users.VerifySecondFactor(ctx, 1, code))
+	assert.Equal(t, users.VerifySecondFactor(ctx, 1, code), ErrInvalidCredentials)
+
+	recovery := strings.ToLower(codes[0])
+	assert.NilError(t, users.VerifySecondFactor(ctx, 1, recovery))
+	assert.Equal(t, users.VerifySecondFactor(ctx, 1, recovery), ErrInvalidCredentials)
+
+	assert.NilError(t, users.DisableTwoFactor(ctx, 1))
+	assert.Equal(t, users.VerifySecondFactor(ctx, 1, codes[1]), ErrInvalidCredentials)
+}
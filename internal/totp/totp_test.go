@@ -0,0 +1,55 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/purple-mountain/snippetbox/internal/assert"
+)
+
+// The SHA1 test vectors of RFC 6238 appendix B, truncated to six digits.
+func TestCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+	for _, tt := range tests {
+		code, err := Code(secret, Step(time.Unix(tt.unix, 0)))
+		assert.NilError(t, err)
+		assert.Equal(t, code, tt.want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.NilError(t, err)
+	now := time.Now()
+	code, err := Code(secret, Step(now))
+	assert.NilError(t, err)
+
+	step, ok := Validate(secret, code, now)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, step, Step(now))
+
+	_, ok = Validate(secret, code, now.Add(30*time.Second))
+	assert.Equal(t, ok, true)
+	_, ok = Validate(secret, code, now.Add(90*time.Second))
+	assert.Equal(t, ok, false)
+	_, ok = Validate(secret, "12345", now)
+	assert.Equal(t, ok, false)
+}
+
+func TestURI(t *testing.T) {
+	got := URI("Snippetbox", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	assert.Equal(t, got, "otpauth://totp/Snippetbox:alice@example.com?algorithm=SHA1&digits=6&issuer=Snippetbox&period=30&secret=JBSWY3DPEHPK3PXP")
+}
@@ -61,5 +61,5 @@ func PermittedValue[T comparable](value T, permittedValues ...T) bool {
 			return true
 		}
 	}
-	return true
+	return false
 }
@@ -0,0 +1,15 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/purple-mountain/snippetbox/internal/assert"
+)
+
+func TestPermittedValue(t *testing.T) {
+	assert.Equal(t, PermittedValue(7, 365, 7, 1), true)
+	assert.Equal(t, PermittedValue(30, 365, 7, 1), false)
+	assert.Equal(t, PermittedValue("read", "read", "write"), true)
+	assert.Equal(t, PermittedValue("admin", "read", "write"), false)
+	assert.Equal(t, PermittedValue("go"), false)
+}
@@ -2,6 +2,6 @@ package ui
 
 import "embed"
 
-//go:embed "html" "static"
+//go:embed "html" "static" "email"
 
 var Files embed.FS